@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"gitlab.com/subrotokumar/playstack/libs/core"
 )
@@ -13,10 +14,47 @@ type Config struct {
 		Host string   `yaml:"host" envconfig:"SERVICE_HOST" default:"0.0.0.0"`
 		Env  core.Env `yaml:"env" envconfig:"SERVICE_ENV" default:"dev"`
 	} `yaml:"app"`
+	// BasicAuth protects operator routes; they stay closed until both are set.
 	BasicAuth struct {
-		Username string `yaml:"username" envconfig:"BASIC_AUTH_USERNAME" default:"admin"`
-		Password string `yaml:"password" envconfig:"BASIC_AUTH_PASSWORD" default:"password"`
+		Username string `yaml:"username" envconfig:"BASIC_AUTH_USERNAME"`
+		Password string `yaml:"password" envconfig:"BASIC_AUTH_PASSWORD"`
 	} `yaml:"basic_auth"`
+	// InternalAuth verifies HMAC-signed transcoder callbacks.
+	InternalAuth struct {
+		Keys    map[string]string `yaml:"keys" envconfig:"INTERNAL_HMAC_KEYS"`
+		MaxSkew time.Duration     `yaml:"max_skew" envconfig:"INTERNAL_HMAC_MAX_SKEW" default:"5m"`
+	} `yaml:"internal_auth"`
+	Idempotency struct {
+		TTL time.Duration `yaml:"ttl" envconfig:"IDEMPOTENCY_TTL" default:"24h"`
+	} `yaml:"idempotency"`
+	Queue struct {
+		TranscodeURL string `yaml:"transcode_url" envconfig:"TRANSCODE_QUEUE_URL"`
+	} `yaml:"queue"`
+	// Reaper recovers videos whose transcoder stopped reporting.
+	Reaper struct {
+		Enabled     bool          `yaml:"enabled" envconfig:"REAPER_ENABLED" default:"true"`
+		Interval    time.Duration `yaml:"interval" envconfig:"REAPER_INTERVAL" default:"1m"`
+		StaleAfter  time.Duration `yaml:"stale_after" envconfig:"REAPER_STALE_AFTER" default:"30m"`
+		MaxAttempts int32         `yaml:"max_attempts" envconfig:"REAPER_MAX_ATTEMPTS" default:"3"`
+	} `yaml:"reaper"`
+	// Reprocess paces admin re-transcode batches.
+	Reprocess struct {
+		RatePerSec   float64       `yaml:"rate_per_sec" envconfig:"REPROCESS_RATE_PER_SEC" default:"1"`
+		Burst        int           `yaml:"burst" envconfig:"REPROCESS_BURST" default:"5"`
+		MaxVideos    int32         `yaml:"max_videos" envconfig:"REPROCESS_MAX_VIDEOS" default:"1000"`
+		PollInterval time.Duration `yaml:"poll_interval" envconfig:"REPROCESS_POLL_INTERVAL" default:"30s"`
+		Retention    time.Duration `yaml:"retention" envconfig:"REPROCESS_RETENTION" default:"720h"`
+	} `yaml:"reprocess"`
+	// Playback signs tokenized HLS URLs for players that cannot send headers.
+	Playback struct {
+		TokenSecret string        `yaml:"token_secret" envconfig:"PLAYBACK_TOKEN_SECRET"`
+		TokenTTL    time.Duration `yaml:"token_ttl" envconfig:"PLAYBACK_TOKEN_TTL" default:"6h"`
+	} `yaml:"playback"`
+	// ContentKeys seals per-video media keys before they are stored.
+	ContentKeys struct {
+		KEKs      map[string]string `yaml:"keks" envconfig:"CONTENT_KEKS"`
+		ActiveKEK string            `yaml:"active_kek" envconfig:"CONTENT_KEK_ID"`
+	} `yaml:"content_keys"`
 	Log struct {
 		Level *string `yaml:"level" envconfig:"LOG_LEVEL" default:"INFO"`
 	} `yaml:"log"`
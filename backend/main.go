@@ -27,6 +27,11 @@ import (
 // @name Authorization
 
 // @securityDefinitions.basic BasicAuth
+
+// @securityDefinitions.apikey HMACSignature
+// @in header
+// @name X-Signature
+// @description HMAC-SHA256 request signature, sent with X-Signature-Key-Id, X-Signature-Timestamp and X-Signature-Nonce
 func main() {
 	svc := server.NewHTTPServer()
 	err := svc.Run()
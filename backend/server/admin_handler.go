@@ -0,0 +1,205 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"gitlab.com/subrotokumar/playstack/libs/db"
+)
+
+const (
+	ErrReprocessFilterRequired = "at least one of video_id, user_id, status, created_after or created_before is required"
+	ErrInvalidDateRange        = "created_after must be before created_before"
+	ErrFailedToListVideos      = "failed to list videos"
+	ErrTranscodeQueueMissing   = "transcode queue is not configured"
+	ErrFailedToCreateBatch     = "failed to create reprocess batch"
+	ErrInvalidBatchID          = "invalid batch id"
+	ErrBatchNotFound           = "reprocess batch not found"
+	ErrFailedToGetBatch        = "failed to get reprocess batch"
+)
+
+const (
+	MsgReprocessDryRun   = "dry run, no videos were enqueued"
+	MsgReprocessAccepted = "videos are queued for reprocessing, poll the batch for progress"
+)
+
+type (
+	// ReprocessRequest selects the videos to transcode again. Filters are
+	// combined with AND; videos still in PREUPLOAD or PROCESSING are never
+	// selected.
+	ReprocessRequest struct {
+		VideoID       *uuid.UUID      `json:"video_id"`
+		UserID        *uuid.UUID      `json:"user_id"`
+		Status        *db.VideoStatus `json:"status" validate:"omitempty,oneof='UPLOADED' 'READY' 'FAILED' 'PRIVATE' 'PUBLIC'"`
+		CreatedAfter  *time.Time      `json:"created_after"`
+		CreatedBefore *time.Time      `json:"created_before"`
+		// Limit caps the number of videos selected, up to
+		// REPROCESS_MAX_VIDEOS.
+		Limit  int32 `json:"limit" validate:"omitempty,min=1"`
+		DryRun bool  `json:"dry_run"`
+		// Packaging selects the manifest formats to publish. The
+		// transcoder default is used when it is empty.
+		Packaging []string `json:"packaging" validate:"omitempty,dive,oneof=DASH HLS"`
+	}
+	ReprocessData struct {
+		DryRun bool `json:"dry_run"`
+		// BatchID identifies the batch the videos were added to. It is
+		// unset for a dry run.
+		BatchID  *uuid.UUID  `json:"batch_id,omitempty"`
+		Count    int         `json:"count"`
+		VideoIDs []uuid.UUID `json:"video_ids"`
+	}
+	ReprocessResponse struct {
+		Data    *ReprocessData `json:"data,omitempty"`
+		Message string         `json:"message,omitempty"`
+		Error   any            `json:"error,omitempty"`
+	}
+	// ReprocessBatchData is the progress of a batch. Counts holds the
+	// number of items by status: PENDING, ENQUEUED, SKIPPED or FAILED.
+	ReprocessBatchData struct {
+		Batch  db.ReprocessBatch  `json:"batch"`
+		Counts map[string]int     `json:"counts"`
+		Items  []db.ReprocessItem `json:"items"`
+	}
+	ReprocessBatchResponse struct {
+		Data    *ReprocessBatchData `json:"data,omitempty"`
+		Message string              `json:"message,omitempty"`
+		Error   any                 `json:"error,omitempty"`
+	}
+)
+
+// ReprocessVideosHandler godoc
+//
+// @Summary      Re-transcode videos (admin)
+// @Description Selects videos by ID or by status, owner and creation date and records them as a reprocess batch. A synthetic upload event is then sent for each, paced by REPROCESS_RATE_PER_SEC; the batch survives restarts and its progress is returned by GET /admin/videos/reprocess/{batchId}. With dry_run the selection is only returned.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body      ReprocessRequest  true  "Video selection"
+// @Success      200   {object}  ReprocessResponse  "Dry run"
+// @Success      202   {object}  ReprocessResponse
+// @Failure      400   {object}  ReprocessResponse
+// @Failure      500   {object}  ReprocessResponse
+// @Failure      503   {object}  ReprocessResponse
+// @Security     BasicAuth
+// @Router       /admin/videos/reprocess [post]
+func (s *Server) ReprocessVideosHandler(c echo.Context) error {
+	body := ReprocessRequest{}
+	if err := RequestBody(c, &body); err != nil {
+		return c.JSON(http.StatusBadRequest, ReprocessResponse{Error: err.Error()})
+	}
+	if body.VideoID == nil && body.UserID == nil && body.Status == nil && body.CreatedAfter == nil && body.CreatedBefore == nil {
+		return c.JSON(http.StatusBadRequest, ReprocessResponse{Error: ErrReprocessFilterRequired})
+	}
+	if body.CreatedAfter != nil && body.CreatedBefore != nil && !body.CreatedAfter.Before(*body.CreatedBefore) {
+		return c.JSON(http.StatusBadRequest, ReprocessResponse{Error: ErrInvalidDateRange})
+	}
+	if !body.DryRun && s.cfg.Queue.TranscodeURL == "" {
+		return c.JSON(http.StatusServiceUnavailable, ReprocessResponse{Error: ErrTranscodeQueueMissing})
+	}
+
+	params := db.ListReprocessableVideosParams{MaxResults: s.cfg.Reprocess.MaxVideos}
+	if body.Limit > 0 && body.Limit < params.MaxResults {
+		params.MaxResults = body.Limit
+	}
+	if body.VideoID != nil {
+		params.ID = pgtype.UUID{Bytes: *body.VideoID, Valid: true}
+	}
+	if body.UserID != nil {
+		params.UserID = pgtype.UUID{Bytes: *body.UserID, Valid: true}
+	}
+	if body.Status != nil {
+		params.Status = db.NullVideoStatus{VideoStatus: *body.Status, Valid: true}
+	}
+	if body.CreatedAfter != nil {
+		params.CreatedAfter = pgtype.Timestamp{Time: body.CreatedAfter.UTC(), Valid: true}
+	}
+	if body.CreatedBefore != nil {
+		params.CreatedBefore = pgtype.Timestamp{Time: body.CreatedBefore.UTC(), Valid: true}
+	}
+
+	ctx := c.Request().Context()
+	videos, err := s.store.ListReprocessableVideos(ctx, params)
+	if err != nil {
+		s.log.Error(ErrFailedToListVideos, "err", err)
+		return c.JSON(http.StatusInternalServerError, ReprocessResponse{Error: ErrFailedToListVideos})
+	}
+	data := &ReprocessData{DryRun: body.DryRun, Count: len(videos), VideoIDs: make([]uuid.UUID, 0, len(videos))}
+	for _, video := range videos {
+		data.VideoIDs = append(data.VideoIDs, video.ID)
+	}
+
+	if body.DryRun {
+		return c.JSON(http.StatusOK, ReprocessResponse{Data: data, Message: MsgReprocessDryRun})
+	}
+	batchID := uuid.Must(uuid.NewV7())
+	packaging := body.Packaging
+	if packaging == nil {
+		packaging = []string{}
+	}
+	err = s.store.ExecTx(ctx, func(q *db.Queries) error {
+		if err := q.CreateReprocessBatch(ctx, db.CreateReprocessBatchParams{ID: batchID, Packaging: packaging}); err != nil {
+			return err
+		}
+		return q.AddReprocessItems(ctx, db.AddReprocessItemsParams{BatchID: batchID, VideoIds: data.VideoIDs})
+	})
+	if err != nil {
+		s.log.Error(ErrFailedToCreateBatch, "err", err)
+		return c.JSON(http.StatusInternalServerError, ReprocessResponse{Error: ErrFailedToCreateBatch})
+	}
+	data.BatchID = &batchID
+	s.log.Info("Reprocessing videos", "count", len(videos), "batch_id", batchID, "request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+	s.reprocessor.Wake()
+	return c.JSON(http.StatusAccepted, ReprocessResponse{Data: data, Message: MsgReprocessAccepted})
+}
+
+// GetReprocessBatchHandler godoc
+//
+// @Summary      Get reprocess batch progress (admin)
+// @Description Returns a reprocess batch with the status of each of its videos: PENDING until its upload event is sent, then ENQUEUED, or SKIPPED or FAILED with the reason in error. Finished batches are deleted after REPROCESS_RETENTION.
+// @Tags         Admin
+// @Produce      json
+// @Param        batchId  path      string  true  "Batch ID"
+// @Success      200      {object}  ReprocessBatchResponse
+// @Failure      400      {object}  ReprocessBatchResponse
+// @Failure      404      {object}  ReprocessBatchResponse
+// @Failure      500      {object}  ReprocessBatchResponse
+// @Security     BasicAuth
+// @Router       /admin/videos/reprocess/{batchId} [get]
+func (s *Server) GetReprocessBatchHandler(c echo.Context) error {
+	batchID, err := uuid.Parse(c.Param("batchId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ReprocessBatchResponse{Error: ErrInvalidBatchID})
+	}
+
+	ctx := c.Request().Context()
+	batch, err := s.store.GetReprocessBatch(ctx, batchID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, ReprocessBatchResponse{Error: ErrBatchNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToGetBatch, "err", err)
+		return c.JSON(http.StatusInternalServerError, ReprocessBatchResponse{Error: ErrFailedToGetBatch})
+	}
+	items, err := s.store.ListReprocessItems(ctx, batchID)
+	if err != nil {
+		s.log.Error(ErrFailedToGetBatch, "err", err)
+		return c.JSON(http.StatusInternalServerError, ReprocessBatchResponse{Error: ErrFailedToGetBatch})
+	}
+
+	data := &ReprocessBatchData{Batch: batch, Items: items, Counts: map[string]int{
+		reprocessPending:  0,
+		reprocessEnqueued: 0,
+		reprocessSkipped:  0,
+		reprocessFailed:   0,
+	}}
+	for _, item := range items {
+		data.Counts[item.Status]++
+	}
+	return c.JSON(http.StatusOK, ReprocessBatchResponse{Data: data})
+}
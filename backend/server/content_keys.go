@@ -0,0 +1,320 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"gitlab.com/subrotokumar/playstack/libs/db"
+)
+
+// Content key schemes, stored in content_keys.scheme.
+const (
+	// SchemeAES128 keys the whole-segment AES-128-CBC encryption of HLS.
+	SchemeAES128 = "AES-128"
+	// SchemeCENC keys the cenc-aes-ctr encryption of DASH, licensed
+	// through W3C ClearKey.
+	SchemeCENC = "CENC"
+)
+
+const (
+	ErrContentKeysDisabled     = "content keys are not configured"
+	ErrContentKeyNotFound      = "content key not found"
+	ErrFailedToStoreContentKey = "failed to store content key"
+	ErrFailedToFetchContentKey = "failed to fetch content key"
+	ErrInvalidKeyID            = "kids must be base64url encoded 16-byte key IDs"
+	ErrInvalidHLSKeyID         = "kid must be a UUID"
+)
+
+const (
+	MsgContentKeyStored = "content key stored successfully"
+)
+
+type (
+	// ContentKeyRequest registers the key a video's media is encrypted
+	// with. Key is base64 in JSON.
+	ContentKeyRequest struct {
+		UserID uuid.UUID `json:"user_id" validate:"required"`
+		Scheme string    `json:"scheme" validate:"required,oneof=AES-128 CENC"`
+		KeyID  uuid.UUID `json:"key_id" validate:"required"`
+		Key    []byte    `json:"key" validate:"len=16"`
+	}
+	ContentKeyData struct {
+		VideoID uuid.UUID `json:"video_id"`
+		Scheme  string    `json:"scheme"`
+		KeyID   uuid.UUID `json:"key_id"`
+	}
+	ContentKeyResponse struct {
+		Data    *ContentKeyData `json:"data,omitempty"`
+		Message string          `json:"message,omitempty"`
+		Error   any             `json:"error,omitempty"`
+	}
+
+	// ClearKeyLicenseRequest is the W3C ClearKey license request a CDM
+	// generates. Key IDs are base64url without padding.
+	ClearKeyLicenseRequest struct {
+		KIDs []string `json:"kids" validate:"required,min=1"`
+		Type string   `json:"type" validate:"omitempty,oneof=temporary persistent-license"`
+	}
+	// ClearKeyLicense is the W3C ClearKey license, a JWK set of the
+	// requested keys.
+	ClearKeyLicense struct {
+		Keys []ClearKeyJWK `json:"keys"`
+		Type string        `json:"type,omitempty"`
+	}
+	ClearKeyJWK struct {
+		Kty string `json:"kty"`
+		K   string `json:"k"`
+		Kid string `json:"kid"`
+	}
+)
+
+// keyring seals content keys with AES-256-GCM under a key-encryption key
+// (KEK). The video ID and scheme are bound as additional data, so a sealed
+// key copied to another video does not open.
+type keyring struct {
+	keks   map[string]cipher.AEAD
+	active string
+}
+
+// newKeyring parses the ContentKeys config. It returns nil when no KEK is
+// configured.
+func newKeyring(keks map[string]string, active string) (*keyring, error) {
+	if len(keks) == 0 {
+		return nil, nil
+	}
+	kr := &keyring{keks: make(map[string]cipher.AEAD, len(keks)), active: active}
+	for id, encoded := range keks {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("kek %q: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("kek %q: got %d bytes, want 32", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("kek %q: %w", id, err)
+		}
+		if kr.keks[id], err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("kek %q: %w", id, err)
+		}
+	}
+	if _, ok := kr.keks[active]; !ok {
+		return nil, fmt.Errorf("active kek %q is not listed in CONTENT_KEKS", active)
+	}
+	return kr, nil
+}
+
+// seal encrypts key with the active KEK. The nonce is prepended to the
+// ciphertext.
+func (kr *keyring) seal(videoID uuid.UUID, scheme string, key []byte) ([]byte, string, error) {
+	aead := kr.keks[kr.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nonce, nonce, key, contentKeyAAD(videoID, scheme)), kr.active, nil
+}
+
+// open decrypts a stored content key with the KEK it was sealed with.
+func (kr *keyring) open(ck db.ContentKey) ([]byte, error) {
+	aead, ok := kr.keks[ck.KekID]
+	if !ok {
+		return nil, fmt.Errorf("kek %q is not configured", ck.KekID)
+	}
+	if len(ck.EncryptedKey) < aead.NonceSize() {
+		return nil, errors.New("sealed content key is too short")
+	}
+	nonce, sealed := ck.EncryptedKey[:aead.NonceSize()], ck.EncryptedKey[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, contentKeyAAD(ck.VideoID, ck.Scheme))
+}
+
+func contentKeyAAD(videoID uuid.UUID, scheme string) []byte {
+	return append(videoID[:], scheme...)
+}
+
+// RegisterContentKeyInternalHandler godoc
+//
+// @Summary      Register a content key (internal)
+// @Description Seals a key the video's media is encrypted with and stores it next to the keys of earlier jobs. It is served once the transcoder reports outputs encrypted with it. The key is never returned.
+// @Tags         Internal
+// @Accept       json
+// @Produce      json
+// @Param        videoId  path      string             true  "Video ID"
+// @Param        body     body      ContentKeyRequest  true  "Content key"
+// @Success      200      {object}  ContentKeyResponse
+// @Failure      400      {object}  ContentKeyResponse
+// @Failure      404      {object}  ContentKeyResponse
+// @Failure      500      {object}  ContentKeyResponse
+// @Failure      503      {object}  ContentKeyResponse
+// @Security     HMACSignature
+// @Router       /internal/media/videos/{videoId}/keys [put]
+func (s *Server) RegisterContentKeyInternalHandler(c echo.Context) error {
+	if s.keyring == nil {
+		return c.JSON(http.StatusServiceUnavailable, ContentKeyResponse{Error: ErrContentKeysDisabled})
+	}
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ContentKeyResponse{Error: ErrInvalidVideoID})
+	}
+	body := ContentKeyRequest{}
+	if err := RequestBody(c, &body); err != nil {
+		return c.JSON(http.StatusBadRequest, ContentKeyResponse{Error: err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	video, err := s.store.GetVideoByID(ctx, videoID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && video.UserID != body.UserID) {
+		return c.JSON(http.StatusNotFound, ContentKeyResponse{Error: ErrVideoNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToFetchVideo, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchVideo})
+	}
+
+	sealed, kekID, err := s.keyring.seal(videoID, body.Scheme, body.Key)
+	if err != nil {
+		s.log.Error(ErrFailedToStoreContentKey, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToStoreContentKey})
+	}
+	ck, err := s.store.UpsertContentKey(ctx, db.UpsertContentKeyParams{
+		VideoID:      videoID,
+		Scheme:       body.Scheme,
+		KeyID:        body.KeyID,
+		EncryptedKey: sealed,
+		KekID:        kekID,
+	})
+	if err != nil {
+		s.log.Error(ErrFailedToStoreContentKey, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToStoreContentKey})
+	}
+	return c.JSON(http.StatusOK, ContentKeyResponse{
+		Data:    &ContentKeyData{VideoID: ck.VideoID, Scheme: ck.Scheme, KeyID: ck.KeyID},
+		Message: MsgContentKeyStored,
+	})
+}
+
+// GetHLSKeyHandler godoc
+//
+// @Summary      Get the HLS decryption key
+// @Description Returns the raw 16-byte AES-128 key referenced by EXT-X-KEY in the video's HLS media playlists, to viewers allowed to watch the video. Without kid, the most recently activated key is returned. Players that cannot send an Authorization header use hls_url from the outputs endpoint instead.
+// @Tags         Media
+// @Produce      application/octet-stream
+// @Param        videoId  path      string  true   "Video ID"
+// @Param        kid      query     string  false  "Key ID"
+// @Success      200      {file}    binary
+// @Failure      400      {object}  ContentKeyResponse
+// @Failure      404      {object}  ContentKeyResponse
+// @Failure      500      {object}  ContentKeyResponse
+// @Failure      503      {object}  ContentKeyResponse
+// @Security     BearerAuth
+// @Router       /media/videos/{videoId}/key [get]
+func (s *Server) GetHLSKeyHandler(c echo.Context) error {
+	if s.keyring == nil {
+		return c.JSON(http.StatusServiceUnavailable, ContentKeyResponse{Error: ErrContentKeysDisabled})
+	}
+	userID := c.Get("sub").(uuid.UUID)
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ContentKeyResponse{Error: ErrInvalidVideoID})
+	}
+	ctx := c.Request().Context()
+	video, err := s.store.GetVideoByID(ctx, videoID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !canWatch(video, userID)) {
+		return c.JSON(http.StatusNotFound, ContentKeyResponse{Error: ErrVideoNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToFetchVideo, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchVideo})
+	}
+
+	return s.serveHLSKey(c, videoID)
+}
+
+// ClearKeyLicenseHandler godoc
+//
+// @Summary      Get a ClearKey license
+// @Description Answers a W3C ClearKey license request for the video's CENC-encrypted DASH output with the requested keys as a JWK set, to viewers allowed to watch the video. Key IDs are looked up among the keys of every job that published the video, so manifests of earlier jobs keep playing. Key IDs the video does not use are ignored; 404 is returned when none match.
+// @Tags         Media
+// @Accept       json
+// @Produce      json
+// @Param        videoId  path      string                  true  "Video ID"
+// @Param        body     body      ClearKeyLicenseRequest  true  "License request"
+// @Success      200      {object}  ClearKeyLicense
+// @Failure      400      {object}  ContentKeyResponse
+// @Failure      404      {object}  ContentKeyResponse
+// @Failure      500      {object}  ContentKeyResponse
+// @Failure      503      {object}  ContentKeyResponse
+// @Security     BearerAuth
+// @Router       /media/videos/{videoId}/license [post]
+func (s *Server) ClearKeyLicenseHandler(c echo.Context) error {
+	if s.keyring == nil {
+		return c.JSON(http.StatusServiceUnavailable, ContentKeyResponse{Error: ErrContentKeysDisabled})
+	}
+	userID := c.Get("sub").(uuid.UUID)
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ContentKeyResponse{Error: ErrInvalidVideoID})
+	}
+	body := ClearKeyLicenseRequest{}
+	if err := RequestBody(c, &body); err != nil {
+		return c.JSON(http.StatusBadRequest, ContentKeyResponse{Error: err.Error()})
+	}
+	kids := make([]uuid.UUID, 0, len(body.KIDs))
+	for _, encoded := range body.KIDs {
+		raw, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != 16 {
+			return c.JSON(http.StatusBadRequest, ContentKeyResponse{Error: ErrInvalidKeyID})
+		}
+		kids = append(kids, uuid.UUID(raw))
+	}
+
+	ctx := c.Request().Context()
+	video, err := s.store.GetVideoByID(ctx, videoID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !canWatch(video, userID)) {
+		return c.JSON(http.StatusNotFound, ContentKeyResponse{Error: ErrVideoNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToFetchVideo, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchVideo})
+	}
+
+	// Every job stores its own key, so a manifest published by an earlier
+	// job still finds its key ID among them.
+	cks, err := s.store.ListContentKeysByKeyIDs(ctx, db.ListContentKeysByKeyIDsParams{
+		VideoID: videoID,
+		Scheme:  SchemeCENC,
+		KeyIds:  kids,
+	})
+	if err != nil {
+		s.log.Error(ErrFailedToFetchContentKey, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchContentKey})
+	}
+	if len(cks) == 0 {
+		return c.JSON(http.StatusNotFound, ContentKeyResponse{Error: ErrContentKeyNotFound})
+	}
+	license := ClearKeyLicense{Keys: make([]ClearKeyJWK, 0, len(cks)), Type: body.Type}
+	for _, ck := range cks {
+		key, err := s.keyring.open(ck)
+		if err != nil {
+			s.log.Error(ErrFailedToFetchContentKey, "video_id", videoID, "kek_id", ck.KekID, "err", err)
+			return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchContentKey})
+		}
+		license.Keys = append(license.Keys, ClearKeyJWK{
+			Kty: "oct",
+			K:   base64.RawURLEncoding.EncodeToString(key),
+			Kid: base64.RawURLEncoding.EncodeToString(ck.KeyID[:]),
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusOK, license)
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"gitlab.com/subrotokumar/playstack/libs/core"
+	"gitlab.com/subrotokumar/playstack/libs/db"
+)
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotencyReplayed = "Idempotent-Replayed"
+)
+
+const (
+	ErrRequestInProgress      = "a request with this idempotency key is in progress"
+	ErrIdempotencyUnavailable = "failed to check idempotency key"
+)
+
+const (
+	// idempotencyReservation bounds how long a request holds its key, so a
+	// replica that died mid-request does not block retries for good.
+	idempotencyReservation = 5 * time.Minute
+	// callbackCleanupInterval is how often expired nonces and idempotency
+	// keys are deleted.
+	callbackCleanupInterval = time.Minute
+)
+
+type (
+	// idempotencyCache remembers successful responses by Idempotency-Key so
+	// a retried callback is answered from the cache instead of being
+	// applied twice. It is stored in Postgres, so retries are deduplicated
+	// whichever replica they reach.
+	idempotencyCache struct {
+		store *db.SQLStore
+		log   *core.Logger
+		ttl   time.Duration
+	}
+	// recordingWriter copies the response body while it is written.
+	recordingWriter struct {
+		http.ResponseWriter
+		body bytes.Buffer
+	}
+	// nonceStore is the hmacauth.NonceStore of signed callbacks, shared by
+	// every replica through Postgres.
+	nonceStore struct {
+		store *db.SQLStore
+	}
+)
+
+func newIdempotencyCache(store *db.SQLStore, log *core.Logger, ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{store: store, log: log, ttl: ttl}
+}
+
+// Middleware replays the stored response of a request carrying a known
+// Idempotency-Key. Only 2xx responses are stored, so failures can be
+// retried with the same key.
+func (ic *idempotencyCache) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+			key = c.Request().Method + " " + c.Request().URL.Path + " " + key
+
+			ctx := context.WithoutCancel(c.Request().Context())
+			cached, ok, err := ic.begin(ctx, key)
+			if err != nil {
+				ic.log.Error(ErrIdempotencyUnavailable, "err", err)
+				return c.JSON(http.StatusServiceUnavailable, echo.Map{"error": ErrIdempotencyUnavailable})
+			}
+			if ok && !cached.Done {
+				return c.JSON(http.StatusConflict, echo.Map{"error": ErrRequestInProgress})
+			}
+			if ok {
+				c.Response().Header().Set(HeaderIdempotencyReplayed, "true")
+				if cached.ContentType != "" {
+					c.Response().Header().Set(echo.HeaderContentType, cached.ContentType)
+				}
+				c.Response().WriteHeader(int(cached.Status))
+				_, err := c.Response().Write(cached.Body)
+				return err
+			}
+
+			recorder := &recordingWriter{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+			err = next(c)
+			c.Response().Writer = recorder.ResponseWriter
+
+			status := c.Response().Status
+			if err != nil || status < 200 || status >= 300 {
+				if err := ic.store.ReleaseIdempotencyKey(ctx, key); err != nil {
+					ic.log.Warn("Failed to release idempotency key", "err", err)
+				}
+				return err
+			}
+			err = ic.store.FinishIdempotencyKey(ctx, db.FinishIdempotencyKeyParams{
+				Status:      int32(status),
+				ContentType: c.Response().Header().Get(echo.HeaderContentType),
+				Body:        recorder.body.Bytes(),
+				Ttl:         interval(ic.ttl),
+				Key:         key,
+			})
+			if err != nil {
+				ic.log.Warn("Failed to store idempotent response", "err", err)
+			}
+			return nil
+		}
+	}
+}
+
+// begin returns the entry for key, or reserves it and reports false when
+// there is none or it expired.
+func (ic *idempotencyCache) begin(ctx context.Context, key string) (db.IdempotencyKey, bool, error) {
+	reserved, err := ic.store.ReserveIdempotencyKey(ctx, db.ReserveIdempotencyKeyParams{
+		Key: key,
+		Ttl: interval(idempotencyReservation),
+	})
+	if err != nil {
+		return db.IdempotencyKey{}, false, err
+	}
+	if reserved > 0 {
+		return db.IdempotencyKey{}, false, nil
+	}
+	entry, err := ic.store.GetIdempotencyKey(ctx, key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Released by a failed request since; the client retries.
+		return db.IdempotencyKey{}, true, nil
+	}
+	return entry, err == nil, err
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Use records nonce and reports false if a live request already used it.
+func (n nonceStore) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	used, err := n.store.UseRequestNonce(ctx, db.UseRequestNonceParams{Nonce: nonce, Ttl: interval(ttl)})
+	return used > 0, err
+}
+
+// cleanupCallbackState deletes expired nonces and idempotency keys every
+// callbackCleanupInterval until ctx is cancelled.
+func (s *Server) cleanupCallbackState(ctx context.Context) {
+	ticker := time.NewTicker(callbackCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.store.DeleteExpiredRequestNonces(ctx); err != nil {
+			s.log.Warn("Failed to delete expired request nonces", "err", err)
+		}
+		if err := s.store.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+			s.log.Warn("Failed to delete expired idempotency keys", "err", err)
+		}
+	}
+}
+
+func interval(d time.Duration) pgtype.Interval {
+	return pgtype.Interval{Microseconds: d.Microseconds(), Valid: true}
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"gitlab.com/subrotokumar/playstack/libs/db"
+)
+
+const (
+	ErrInvalidJobID      = "invalid job ID"
+	ErrJobNotFound       = "transcoding job not found"
+	ErrFailedToCreateJob = "failed to create transcoding job"
+	ErrFailedToUpdateJob = "failed to update transcoding job"
+	ErrFailedToFetchJobs = "failed to fetch transcoding jobs"
+)
+
+// maxJobErrorMessageLength bounds the failure reason stored per job.
+const maxJobErrorMessageLength = 4096
+
+type (
+	CreateJobRequest struct {
+		UserID uuid.UUID `json:"user_id" validate:"required"`
+		// QueueManaged is set when SQS redelivers the source message if
+		// the worker dies, so the reaper does not enqueue another copy.
+		QueueManaged bool `json:"queue_managed"`
+	}
+	HeartbeatRequest struct {
+		UserID uuid.UUID `json:"user_id" validate:"required"`
+	}
+	UpdateJobRequest struct {
+		Status       db.JobStatus `json:"status" validate:"required,oneof='PENDING' 'RUNNING' 'SUCCESS' 'FAILED'"`
+		ErrorMessage *string      `json:"error_message"`
+		// Ladder is how the encoded ladder was chosen, recorded for
+		// auditing.
+		Ladder *JobLadder `json:"ladder" validate:"omitempty"`
+	}
+	JobLadder struct {
+		Method   string          `json:"method" validate:"required,oneof=fixed per_title"`
+		Fallback string          `json:"fallback,omitempty"`
+		Rungs    []JobLadderRung `json:"rungs" validate:"dive"`
+	}
+	JobLadderRung struct {
+		Name           string           `json:"name" validate:"required"`
+		Codec          string           `json:"codec"`
+		Resolution     string           `json:"resolution"`
+		ConfiguredKbps int              `json:"configured_kbps" validate:"min=0"`
+		BitrateKbps    int              `json:"bitrate_kbps" validate:"min=0"`
+		CRF            *int             `json:"crf,omitempty"`
+		Trials         []JobLadderTrial `json:"trials,omitempty"`
+	}
+	JobLadderTrial struct {
+		CRF         int     `json:"crf"`
+		BitrateKbps int     `json:"bitrate_kbps"`
+		PSNR        float64 `json:"psnr"`
+		SSIM        float64 `json:"ssim"`
+	}
+	JobResponse struct {
+		Data    *db.TranscodingJob `json:"data,omitempty"`
+		Message string             `json:"message,omitempty"`
+		Error   any                `json:"error,omitempty"`
+	}
+	ListJobsResponse struct {
+		Data    []db.TranscodingJob `json:"data"`
+		Message string              `json:"message,omitempty"`
+		Error   any                 `json:"error,omitempty"`
+	}
+)
+
+// CreateJobInternalHandler godoc
+//
+// @Summary      Start a transcoding job (internal)
+// @Description Records a new PENDING processing attempt for a video, with the video status it started from as prior_status
+// @Tags         Internal
+// @Accept       json
+// @Produce      json
+// @Param        videoId  path      string            true  "Video ID"
+// @Param        body     body      CreateJobRequest  true  "Job owner"
+// @Success      201      {object}  JobResponse
+// @Failure      400      {object}  JobResponse
+// @Failure      404      {object}  JobResponse
+// @Failure      500      {object}  JobResponse
+// @Security     HMACSignature
+// @Router       /internal/media/videos/{videoId}/jobs [post]
+func (s *Server) CreateJobInternalHandler(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, JobResponse{Error: ErrInvalidVideoID})
+	}
+	body := CreateJobRequest{}
+	if err := RequestBody(c, &body); err != nil {
+		return c.JSON(http.StatusBadRequest, JobResponse{Error: err.Error()})
+	}
+
+	// The video row is locked while the attempt number is taken, so
+	// concurrent deliveries of the same video get consecutive attempts
+	// instead of colliding on the unique (video_id, attempt) index.
+	ctx := c.Request().Context()
+	var job db.TranscodingJob
+	err = s.store.ExecTx(ctx, func(q *db.Queries) error {
+		video, err := q.GetVideoByIDForUpdate(ctx, videoID)
+		if err != nil {
+			return err
+		}
+		if video.UserID != body.UserID {
+			return pgx.ErrNoRows
+		}
+		job, err = q.CreateTranscodingJob(ctx, db.CreateTranscodingJobParams{
+			ID:           uuid.Must(uuid.NewV7()),
+			VideoID:      videoID,
+			Status:       db.JobStatusPENDING,
+			QueueManaged: body.QueueManaged,
+			PriorStatus:  pgtype.Text{String: string(video.Status), Valid: true},
+		})
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, JobResponse{Error: ErrVideoNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToCreateJob, "err", err)
+		return c.JSON(http.StatusInternalServerError, JobResponse{Error: ErrFailedToCreateJob})
+	}
+	return c.JSON(http.StatusCreated, JobResponse{Data: &job})
+}
+
+// HeartbeatInternalHandler godoc
+//
+// @Summary      Report a live transcoder (internal)
+// @Description Refreshes updated_at of a PROCESSING video so the stale job reaper leaves it alone. Sent periodically through every stage of a job.
+// @Tags         Internal
+// @Accept       json
+// @Produce      json
+// @Param        videoId  path      string            true  "Video ID"
+// @Param        body     body      HeartbeatRequest  true  "Job owner"
+// @Success      200      {string}  string "OK"
+// @Failure      400      {object}  JobResponse
+// @Failure      500      {object}  JobResponse
+// @Security     HMACSignature
+// @Router       /internal/media/videos/{videoId}/heartbeat [post]
+func (s *Server) HeartbeatInternalHandler(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, JobResponse{Error: ErrInvalidVideoID})
+	}
+	body := HeartbeatRequest{}
+	if err := RequestBody(c, &body); err != nil {
+		return c.JSON(http.StatusBadRequest, JobResponse{Error: err.Error()})
+	}
+	err = s.store.TouchProcessingVideo(c.Request().Context(), db.TouchProcessingVideoParams{ID: videoID, UserID: body.UserID})
+	if err != nil {
+		s.log.Error(ErrFailedToUpdateMetadata, "err", err)
+		return c.JSON(http.StatusInternalServerError, JobResponse{Error: ErrFailedToUpdateMetadata})
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// UpdateJobInternalHandler godoc
+//
+// @Summary      Update a transcoding job (internal)
+// @Description Moves a job to a new state and records the failure reason
+// @Tags         Internal
+// @Accept       json
+// @Produce      json
+// @Param        videoId  path      string            true  "Video ID"
+// @Param        jobId    path      string            true  "Job ID"
+// @Param        body     body      UpdateJobRequest  true  "Job state"
+// @Success      200      {object}  JobResponse
+// @Failure      400      {object}  JobResponse
+// @Failure      404      {object}  JobResponse
+// @Failure      500      {object}  JobResponse
+// @Security     HMACSignature
+// @Router       /internal/media/videos/{videoId}/jobs/{jobId} [patch]
+func (s *Server) UpdateJobInternalHandler(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, JobResponse{Error: ErrInvalidVideoID})
+	}
+	jobID, err := uuid.Parse(c.Param("jobId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, JobResponse{Error: ErrInvalidJobID})
+	}
+	body := UpdateJobRequest{}
+	if err := RequestBody(c, &body); err != nil {
+		return c.JSON(http.StatusBadRequest, JobResponse{Error: err.Error()})
+	}
+
+	params := db.UpdateTranscodingJobStatusParams{
+		Status:  body.Status,
+		ID:      jobID,
+		VideoID: videoID,
+	}
+	if body.ErrorMessage != nil {
+		message := *body.ErrorMessage
+		if len(message) > maxJobErrorMessageLength {
+			message = strings.ToValidUTF8(message[:maxJobErrorMessageLength], "")
+		}
+		params.ErrorMessage = pgtype.Text{String: message, Valid: true}
+	}
+	if body.Ladder != nil {
+		if params.Ladder, err = json.Marshal(body.Ladder); err != nil {
+			s.log.Error(ErrFailedToUpdateJob, "err", err)
+			return c.JSON(http.StatusInternalServerError, JobResponse{Error: ErrFailedToUpdateJob})
+		}
+	}
+
+	job, err := s.store.UpdateTranscodingJobStatus(c.Request().Context(), params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, JobResponse{Error: ErrJobNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToUpdateJob, "err", err)
+		return c.JSON(http.StatusInternalServerError, JobResponse{Error: ErrFailedToUpdateJob})
+	}
+	return c.JSON(http.StatusOK, JobResponse{Data: &job})
+}
+
+// ListJobsHandler godoc
+//
+// @Summary      List transcoding jobs
+// @Description Returns every processing attempt of a video, newest first
+// @Tags         Media
+// @Produce      json
+// @Param        videoId  path      string  true  "Video ID"
+// @Success      200      {object}  ListJobsResponse
+// @Failure      400      {object}  ListJobsResponse
+// @Failure      403      {object}  ListJobsResponse
+// @Failure      404      {object}  ListJobsResponse
+// @Failure      500      {object}  ListJobsResponse
+// @Security     BearerAuth
+// @Router       /media/videos/{videoId}/jobs [get]
+func (s *Server) ListJobsHandler(c echo.Context) error {
+	userID := c.Get("sub").(uuid.UUID)
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ListJobsResponse{Error: ErrInvalidVideoID})
+	}
+
+	ctx := c.Request().Context()
+	video, err := s.store.GetVideoByID(ctx, videoID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, ListJobsResponse{Error: ErrVideoNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToFetchVideo, "err", err)
+		return c.JSON(http.StatusInternalServerError, ListJobsResponse{Error: ErrFailedToFetchVideo})
+	}
+	if video.UserID != userID {
+		return c.JSON(http.StatusForbidden, ListJobsResponse{Error: ErrNoPermission})
+	}
+
+	jobs, err := s.store.ListTranscodingJobs(ctx, videoID)
+	if err != nil {
+		s.log.Error(ErrFailedToFetchJobs, "err", err)
+		return c.JSON(http.StatusInternalServerError, ListJobsResponse{Error: ErrFailedToFetchJobs})
+	}
+	return c.JSON(http.StatusOK, ListJobsResponse{Data: jobs})
+}
@@ -1,13 +1,17 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 	"gitlab.com/subrotokumar/playstack/libs/db"
@@ -25,10 +29,13 @@ const (
 	ErrFailedToFetchVideo           = "failed to fetch video"
 	ErrNoPermission                 = "you do not have permission to perform this action"
 	ErrFailedToUpdateMetadata       = "failed to update video metadata"
+	ErrUnknownThumbnail             = "key is not a thumbnail candidate of this video"
+	ErrUnsupportedFormat            = "unsupported video format"
 )
 
 const (
 	MsgPresignedURLGenerated = "presigned URL generated successfully"
+	MsgPosterUpdated         = "poster updated successfully"
 )
 
 type (
@@ -71,12 +78,44 @@ type (
 	}
 
 	UpdateMetadataRequest struct {
-		UserID      uuid.UUID       `json:"user_id"`
-		Title       *string         `json:"title"`
-		Status      *db.VideoStatus `json:"status" validate:"omitempty,oneof='PREUPLOAD' 'UPLOADED' 'PROCESSING' 'READY' 'FAILED'"`
-		DurationSec *int32          `json:"duration_sec"`
+		UserID        uuid.UUID       `json:"user_id"`
+		Title         *string         `json:"title"`
+		Status        *db.VideoStatus `json:"status" validate:"omitempty,oneof='PREUPLOAD' 'UPLOADED' 'PROCESSING' 'READY' 'FAILED'"`
+		DurationSec   *int32          `json:"duration_sec"`
+		Progress      *int16          `json:"progress" validate:"omitempty,min=0,max=100"`
+		PosterKey     *string         `json:"poster_key"`
+		ThumbnailKeys []string        `json:"thumbnail_keys"`
+		Metadata      *VideoMetadata  `json:"metadata"`
+		// FailureReason is the rejection code stored with a status change,
+		// e.g. NO_VIDEO_STREAM. It is cleared by a status change without one.
+		FailureReason *string `json:"failure_reason" validate:"omitempty,max=64"`
+	}
+	// VideoMetadata is the probe summary reported by the transcoder and
+	// stored in videos.metadata.
+	VideoMetadata struct {
+		Container     string  `json:"container"`
+		VideoCodec    string  `json:"video_codec,omitempty"`
+		Width         int     `json:"width,omitempty" validate:"min=0"`
+		Height        int     `json:"height,omitempty" validate:"min=0"`
+		FPS           float64 `json:"fps,omitempty" validate:"min=0"`
+		BitrateKbps   int     `json:"bitrate_kbps,omitempty" validate:"min=0"`
+		AudioCodec    string  `json:"audio_codec,omitempty"`
+		AudioChannels int     `json:"audio_channels,omitempty" validate:"min=0"`
+	}
+
+	SelectPosterRequest struct {
+		Key string `json:"key" validate:"required"`
+	}
+	SelectPosterResponse struct {
+		Message string `json:"message,omitempty"`
+		Error   any    `json:"error,omitempty"`
 	}
 
+	VideoResponse struct {
+		Data    *db.Video `json:"data,omitempty"`
+		Message string    `json:"message,omitempty"`
+		Error   any       `json:"error,omitempty"`
+	}
 	GetVideoResponse struct {
 		Data    []db.Video `json:"data"`
 		Message string     `json:"message,omitempty"`
@@ -87,7 +126,7 @@ type (
 // VideoAssetsHandler godoc
 //
 // @Summary      Create presigned URL for video upload
-// @Description Creates a video record and returns a presigned POST URL for uploading raw media
+// @Description Creates a video record and returns a presigned PUT URL for uploading raw media. The container is taken from content_type, or from the extension of name when the type is generic; MP4, MOV, MKV, WebM and AVI are accepted. The URL is signed for content_type exactly as declared, so the PUT must send that Content-Type, as returned in header.
 // @Tags         Media
 // @Accept       json
 // @Produce      json
@@ -103,23 +142,32 @@ func (s *Server) VideoAssetsHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, AssetsResponse{Error: err.Error()})
 	}
 
+	format, ok := lookupUploadFormat(body.ContentType, body.Name)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, AssetsResponse{Error: fmt.Sprintf("%s, expected one of %s", ErrUnsupportedFormat, supportedUploadTypes())})
+	}
+
 	videoId := uuid.Must(uuid.NewV7())
 	userId := c.Get("sub").(uuid.UUID)
-	key := fmt.Sprintf("videos/%s/%s/%s", userId.String(), videoId.String(), "video.mp4")
+	key := sourceKey(userId, videoId, format)
 	_, err := s.store.CreateVideo(c.Request().Context(), db.CreateVideoParams{
 		ID:          videoId,
 		UserID:      userId,
 		Title:       body.Name,
 		Status:      db.VideoStatusPREUPLOAD,
 		DurationSec: pgtype.Int4{Valid: false},
+		SourceKey:   key,
 	})
 	if err != nil {
 		s.log.Error(ErrFailedToCreateVideoRecord, "err", err)
 		return c.JSON(http.StatusInternalServerError, AssetsResponse{Error: ErrFailedToCreateVideoRecord})
 	}
 	presignedUrl, err := s.storage.PresignedClient().PresignPutObject(c.Request().Context(), &s3.PutObjectInput{
-		Bucket:        aws.String(s.cfg.S3.RawMediaBucket),
-		Key:           aws.String(key),
+		Bucket: aws.String(s.cfg.S3.RawMediaBucket),
+		Key:    aws.String(key),
+		// Signed with the type the client declared, which is what it sends
+		// on the PUT, e.g. the File's own type; the container is taken from
+		// the key extension.
 		ContentType:   aws.String(body.ContentType),
 		ContentLength: aws.Int64(int64(body.Size)),
 		Metadata:      map[string]string{},
@@ -133,7 +181,7 @@ func (s *Server) VideoAssetsHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, AssetsResponse{
 		Data: &AssetsResponseData{
 			UploadUrl: presignedUrl.URL,
-			Header:    &map[string]string{},
+			Header:    &map[string]string{echo.HeaderContentType: body.ContentType},
 			Asset: &Asset{
 				Id:           videoId,
 				Name:         body.Name,
@@ -207,10 +255,60 @@ func (s *Server) ThumbnailSignedUrlHandler(c echo.Context) error {
 	})
 }
 
+// SelectPosterHandler godoc
+//
+// @Summary      Select video poster
+// @Description Makes one of the generated thumbnail candidates the video poster
+// @Tags         Media
+// @Accept       json
+// @Produce      json
+// @Param        videoId  path      string               true  "Video ID"
+// @Param        body     body      SelectPosterRequest  true  "Thumbnail candidate key"
+// @Success      200      {object}  SelectPosterResponse
+// @Failure      400      {object}  SelectPosterResponse
+// @Failure      403      {object}  SelectPosterResponse
+// @Failure      500      {object}  SelectPosterResponse
+// @Security     BearerAuth
+// @Router       /media/videos/{videoId}/poster [put]
+func (s *Server) SelectPosterHandler(c echo.Context) error {
+	userId := c.Get("sub").(uuid.UUID)
+	videoId, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, SelectPosterResponse{Error: ErrInvalidVideoID})
+	}
+	body := SelectPosterRequest{}
+	if err := RequestBody(c, &body); err != nil {
+		return c.JSON(http.StatusBadRequest, SelectPosterResponse{Error: err.Error()})
+	}
+
+	video, err := s.store.GetVideoByID(c.Request().Context(), videoId)
+	if err != nil {
+		s.log.Error(ErrFailedToFetchVideo, "err", err)
+		return c.JSON(http.StatusInternalServerError, SelectPosterResponse{Error: ErrFailedToFetchVideo})
+	}
+	if video.UserID != userId {
+		return c.JSON(http.StatusForbidden, SelectPosterResponse{Error: ErrNoPermission})
+	}
+	if !slices.Contains(video.ThumbnailKeys, body.Key) {
+		return c.JSON(http.StatusBadRequest, SelectPosterResponse{Error: ErrUnknownThumbnail})
+	}
+
+	err = s.store.PatchVideos(c.Request().Context(), db.PatchVideosParams{
+		ID:        videoId,
+		UserID:    userId,
+		PosterKey: pgtype.Text{String: body.Key, Valid: true},
+	})
+	if err != nil {
+		s.log.Error(ErrFailedToUpdateMetadata, "err", err)
+		return c.JSON(http.StatusInternalServerError, SelectPosterResponse{Error: ErrFailedToUpdateMetadata})
+	}
+	return c.JSON(http.StatusOK, SelectPosterResponse{Message: MsgPosterUpdated})
+}
+
 // UpdateMediaInternalHandler godoc
 //
 // @Summary      Update video metadata (internal)
-// @Description Updates title, status, or duration of a video
+// @Description Updates title, status, duration, processing progress or failure reason of a video
 // @Tags         Internal
 // @Accept       json
 // @Produce      json
@@ -219,7 +317,7 @@ func (s *Server) ThumbnailSignedUrlHandler(c echo.Context) error {
 // @Success      200      {string}  string "OK"
 // @Failure      400      {object}  AssetsResponse
 // @Failure      500      {object}  AssetsResponse
-// @Security     BasicAuth
+// @Security     HMACSignature
 // @Router       /internal/media/videos/{videoId} [patch]
 func (s *Server) UpdateMediaInternalHandler(c echo.Context) error {
 	videoID, err := uuid.Parse(c.Param("videoId"))
@@ -255,6 +353,37 @@ func (s *Server) UpdateMediaInternalHandler(c echo.Context) error {
 			Valid: true,
 		}
 	}
+
+	if body.Progress != nil {
+		params.Progress = pgtype.Int2{
+			Int16: *body.Progress,
+			Valid: true,
+		}
+	}
+
+	if body.PosterKey != nil {
+		params.PosterKey = pgtype.Text{
+			String: *body.PosterKey,
+			Valid:  true,
+		}
+	}
+	params.ThumbnailKeys = body.ThumbnailKeys
+
+	if body.Metadata != nil {
+		metadata, err := json.Marshal(body.Metadata)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, AssetsResponse{Error: err.Error()})
+		}
+		params.Metadata = metadata
+	}
+
+	if body.FailureReason != nil {
+		params.FailureReason = pgtype.Text{
+			String: *body.FailureReason,
+			Valid:  true,
+		}
+	}
+
 	if err := s.store.PatchVideos(c.Request().Context(), params); err != nil {
 		s.log.Error(ErrFailedToUpdateMetadata, "err", err)
 		return c.JSON(http.StatusInternalServerError, AssetsResponse{Error: ErrFailedToUpdateMetadata})
@@ -286,3 +415,34 @@ func (s *Server) GetVideoHandler(c echo.Context) error {
 		Data: resp,
 	})
 }
+
+// GetVideoByIDHandler godoc
+//
+// @Summary      Get a video
+// @Description Returns a video, including the failure reason of a rejected upload. Other users only see published videos.
+// @Tags         Media
+// @Produce      json
+// @Param        videoId  path      string  true  "Video ID"
+// @Success      200      {object}  VideoResponse
+// @Failure      400      {object}  VideoResponse
+// @Failure      404      {object}  VideoResponse
+// @Failure      500      {object}  VideoResponse
+// @Security     BearerAuth
+// @Router       /media/videos/{videoId} [get]
+func (s *Server) GetVideoByIDHandler(c echo.Context) error {
+	userID := c.Get("sub").(uuid.UUID)
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, VideoResponse{Error: ErrInvalidVideoID})
+	}
+
+	video, err := s.store.GetVideoByID(c.Request().Context(), videoID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !canWatch(video, userID)) {
+		return c.JSON(http.StatusNotFound, VideoResponse{Error: ErrVideoNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToFetchVideo, "err", err)
+		return c.JSON(http.StatusInternalServerError, VideoResponse{Error: ErrFailedToFetchVideo})
+	}
+	return c.JSON(http.StatusOK, VideoResponse{Data: &video})
+}
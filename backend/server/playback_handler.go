@@ -0,0 +1,210 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"gitlab.com/subrotokumar/playstack/libs/db"
+)
+
+const (
+	ErrVideoNotFound        = "video not found"
+	ErrFailedToStoreOutputs = "failed to store video outputs"
+	ErrFailedToFetchOutputs = "failed to fetch video outputs"
+)
+
+const (
+	MsgVideoOutputsStored = "video outputs stored successfully"
+)
+
+type (
+	RenditionRequest struct {
+		Resolution  string `json:"resolution" validate:"required,oneof=240p 360p 480p 720p 1080p 1440p 2160p"`
+		BitrateKbps int32  `json:"bitrate_kbps" validate:"required,gt=0"`
+		S3Key       string `json:"s3_key" validate:"required"`
+		// Codec is the video codec family. Renditions reported without it
+		// are H.264.
+		Codec string `json:"codec" validate:"omitempty,oneof=h264 hevc av1"`
+	}
+	ManifestRequest struct {
+		Type  string `json:"type" validate:"required,oneof=DASH HLS THUMBNAILS"`
+		S3Key string `json:"s3_key" validate:"required"`
+	}
+	UpdateOutputsRequest struct {
+		UserID     uuid.UUID          `json:"user_id" validate:"required"`
+		Renditions []RenditionRequest `json:"renditions" validate:"required,min=1,dive"`
+		Manifests  []ManifestRequest  `json:"manifests" validate:"required,min=1,dive"`
+		// KeyIDs are the content keys the outputs are encrypted with. They
+		// are activated together with the manifests that reference them.
+		KeyIDs []uuid.UUID `json:"key_ids"`
+	}
+
+	PlaybackData struct {
+		Manifests  []db.Manifest       `json:"manifests"`
+		Renditions []db.VideoRendition `json:"renditions"`
+		// HLSURL is the tokenized master playlist, playable without an
+		// Authorization header until HLSURLExpiresAt.
+		HLSURL          string     `json:"hls_url,omitempty"`
+		HLSURLExpiresAt *time.Time `json:"hls_url_expires_at,omitempty"`
+	}
+	PlaybackResponse struct {
+		Data    *PlaybackData `json:"data,omitempty"`
+		Message string        `json:"message,omitempty"`
+		Error   any           `json:"error,omitempty"`
+	}
+)
+
+// UpdateOutputsInternalHandler godoc
+//
+// @Summary      Store transcoder outputs (internal)
+// @Description Replaces the renditions and manifests recorded for a video and activates the content keys they are encrypted with
+// @Tags         Internal
+// @Accept       json
+// @Produce      json
+// @Param        videoId  path      string                true  "Video ID"
+// @Param        body     body      UpdateOutputsRequest  true  "Renditions and manifests"
+// @Success      200      {object}  PlaybackResponse
+// @Failure      400      {object}  PlaybackResponse
+// @Failure      404      {object}  PlaybackResponse
+// @Failure      500      {object}  PlaybackResponse
+// @Security     HMACSignature
+// @Router       /internal/media/videos/{videoId}/outputs [put]
+func (s *Server) UpdateOutputsInternalHandler(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, PlaybackResponse{Error: ErrInvalidVideoID})
+	}
+	body := UpdateOutputsRequest{}
+	if err := RequestBody(c, &body); err != nil {
+		return c.JSON(http.StatusBadRequest, PlaybackResponse{Error: err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	data := &PlaybackData{}
+	err = s.store.ExecTx(ctx, func(q *db.Queries) error {
+		video, err := q.GetVideoByID(ctx, videoID)
+		if err != nil {
+			return err
+		}
+		if video.UserID != body.UserID {
+			return pgx.ErrNoRows
+		}
+
+		if err := q.DeleteVideoRenditions(ctx, videoID); err != nil {
+			return err
+		}
+		for _, r := range body.Renditions {
+			if r.Codec == "" {
+				r.Codec = "h264"
+			}
+			rendition, err := q.CreateVideoRendition(ctx, db.CreateVideoRenditionParams{
+				ID:          uuid.Must(uuid.NewV7()),
+				VideoID:     videoID,
+				Resolution:  r.Resolution,
+				BitrateKbps: r.BitrateKbps,
+				S3Key:       r.S3Key,
+				Codec:       r.Codec,
+			})
+			if err != nil {
+				return err
+			}
+			data.Renditions = append(data.Renditions, rendition)
+		}
+		for _, m := range body.Manifests {
+			manifest, err := q.UpsertManifest(ctx, db.UpsertManifestParams{
+				ID:      uuid.Must(uuid.NewV7()),
+				VideoID: videoID,
+				S3Key:   m.S3Key,
+				Type:    m.Type,
+			})
+			if err != nil {
+				return err
+			}
+			data.Manifests = append(data.Manifests, manifest)
+		}
+		if len(body.KeyIDs) == 0 {
+			return nil
+		}
+		_, err = q.ActivateContentKeys(ctx, db.ActivateContentKeysParams{
+			UserID:  body.UserID,
+			VideoID: videoID,
+			KeyIds:  body.KeyIDs,
+		})
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, PlaybackResponse{Error: ErrVideoNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToStoreOutputs, "err", err)
+		return c.JSON(http.StatusInternalServerError, PlaybackResponse{Error: ErrFailedToStoreOutputs})
+	}
+
+	return c.JSON(http.StatusOK, PlaybackResponse{Data: data, Message: MsgVideoOutputsStored})
+}
+
+// PlaybackHandler godoc
+//
+// @Summary      Get playable outputs
+// @Description Returns the manifests and renditions of a video, and a tokenized HLS master playlist URL for players that cannot send an Authorization header
+// @Tags         Media
+// @Produce      json
+// @Param        videoId  path      string  true  "Video ID"
+// @Success      200      {object}  PlaybackResponse
+// @Failure      400      {object}  PlaybackResponse
+// @Failure      404      {object}  PlaybackResponse
+// @Failure      500      {object}  PlaybackResponse
+// @Security     BearerAuth
+// @Router       /media/videos/{videoId}/outputs [get]
+func (s *Server) PlaybackHandler(c echo.Context) error {
+	userID := c.Get("sub").(uuid.UUID)
+	videoID, err := uuid.Parse(c.Param("videoId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, PlaybackResponse{Error: ErrInvalidVideoID})
+	}
+
+	ctx := c.Request().Context()
+	video, err := s.store.GetVideoByID(ctx, videoID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !canWatch(video, userID)) {
+		return c.JSON(http.StatusNotFound, PlaybackResponse{Error: ErrVideoNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToFetchVideo, "err", err)
+		return c.JSON(http.StatusInternalServerError, PlaybackResponse{Error: ErrFailedToFetchVideo})
+	}
+
+	manifests, err := s.store.ListManifests(ctx, videoID)
+	if err != nil {
+		s.log.Error(ErrFailedToFetchOutputs, "err", err)
+		return c.JSON(http.StatusInternalServerError, PlaybackResponse{Error: ErrFailedToFetchOutputs})
+	}
+	renditions, err := s.store.ListVideoRenditions(ctx, videoID)
+	if err != nil {
+		s.log.Error(ErrFailedToFetchOutputs, "err", err)
+		return c.JSON(http.StatusInternalServerError, PlaybackResponse{Error: ErrFailedToFetchOutputs})
+	}
+
+	data := &PlaybackData{Manifests: manifests, Renditions: renditions}
+	if hlsURL, expires := s.signedHLSURL(c, video, manifests); hlsURL != "" {
+		data.HLSURL, data.HLSURLExpiresAt = hlsURL, &expires
+	}
+	return c.JSON(http.StatusOK, PlaybackResponse{Data: data})
+}
+
+// canWatch reports whether userID may play video: owners always can, other
+// users only once it is published.
+func canWatch(video db.Video, userID uuid.UUID) bool {
+	if video.UserID == userID {
+		return true
+	}
+	switch video.Status {
+	case db.VideoStatusREADY, db.VideoStatusPUBLIC:
+		return true
+	default:
+		return false
+	}
+}
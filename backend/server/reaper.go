@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus"
+	"gitlab.com/subrotokumar/playstack/libs/db"
+)
+
+// Reaper actions, used as the "action" label of the actions counter.
+const (
+	reaperRequeued = "requeued"
+	reaperReleased = "released"
+	reaperFailed   = "failed"
+	reaperSkipped  = "skipped"
+	reaperErrored  = "error"
+)
+
+// reaper finds videos stuck in PROCESSING, e.g. because their transcoder
+// was killed, and re-enqueues them until they run out of attempts.
+type reaper struct {
+	s       *Server
+	actions *prometheus.CounterVec
+}
+
+func newReaper(s *Server) *reaper {
+	actions := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "playstack_reaper_actions_total",
+			Help: "Stale processing videos handled by the reaper, partitioned by action.",
+		},
+		[]string{"action"},
+	)
+	if err := s.metrics.Register(actions); err != nil {
+		s.log.Fatal(err.Error())
+	}
+	return &reaper{s: s, actions: actions}
+}
+
+// Run reaps stale videos every Reaper.Interval until ctx is cancelled.
+func (r *reaper) Run(ctx context.Context) {
+	cfg := r.s.cfg.Reaper
+	r.s.log.Info("Reaper started", "interval", cfg.Interval, "stale_after", cfg.StaleAfter, "max_attempts", cfg.MaxAttempts)
+	if r.s.cfg.Queue.TranscodeURL == "" {
+		r.s.log.Warn("TRANSCODE_QUEUE_URL is not set, stale videos will be marked FAILED")
+	}
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		r.reap(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *reaper) reap(ctx context.Context) {
+	staleAfter := pgtype.Interval{Microseconds: r.s.cfg.Reaper.StaleAfter.Microseconds(), Valid: true}
+	videos, err := r.s.store.ListStaleProcessingVideos(ctx, staleAfter)
+	if err != nil {
+		r.s.log.Error("Failed to list stale videos", "err", err)
+		return
+	}
+	for _, video := range videos {
+		if ctx.Err() != nil {
+			return
+		}
+		action := r.reapVideo(ctx, video)
+		r.actions.WithLabelValues(action).Inc()
+	}
+}
+
+// reapVideo fails the video's last job and either re-enqueues the video or,
+// once it used up its attempts, marks it FAILED. A job whose source message
+// SQS redelivers on its own is only released back to UPLOADED, so the
+// video is not transcoded twice. Videos that reported progress since they
+// were listed, or that another backend instance claimed first, are skipped.
+func (r *reaper) reapVideo(ctx context.Context, video db.Video) string {
+	log := r.s.log.With("video_id", video.ID, "stale_since", video.UpdatedAt.Time)
+
+	jobs, err := r.s.store.ListTranscodingJobs(ctx, video.ID)
+	if err != nil {
+		log.Error("Failed to list transcoding jobs", "err", err)
+		return reaperErrored
+	}
+	var attempts int32
+	var queueManaged bool
+	if len(jobs) > 0 {
+		attempts, queueManaged = jobs[0].Attempt, jobs[0].QueueManaged
+	}
+
+	action, status := reaperRequeued, db.VideoStatusUPLOADED
+	switch {
+	case attempts >= r.s.cfg.Reaper.MaxAttempts:
+		action, status = reaperFailed, db.VideoStatusFAILED
+	case queueManaged:
+		action = reaperReleased
+	case r.s.cfg.Queue.TranscodeURL == "":
+		action, status = reaperFailed, db.VideoStatusFAILED
+	}
+	video, err = r.s.store.UpdateStaleVideoStatus(ctx, db.UpdateStaleVideoStatusParams{
+		Status:    status,
+		ID:        video.ID,
+		UpdatedAt: video.UpdatedAt,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Info("Stale video changed before it was reaped, skipping")
+		return reaperSkipped
+	}
+	if err != nil {
+		log.Error("Failed to claim stale video", "err", err)
+		return reaperErrored
+	}
+
+	if len(jobs) > 0 {
+		r.failJob(ctx, jobs[0])
+	}
+
+	if action == reaperReleased {
+		log.Info("Released stale video for its redelivered message", "attempts", attempts)
+		return reaperReleased
+	}
+	if action == reaperRequeued {
+		if err := r.s.enqueueTranscode(ctx, video, nil); err != nil {
+			log.Error("Failed to re-enqueue stale video, marking it FAILED", "attempts", attempts, "err", err)
+			if _, err := r.s.store.UpdateVideoStatus(ctx, db.UpdateVideoStatusParams{ID: video.ID, Status: db.VideoStatusFAILED}); err != nil {
+				log.Error("Failed to mark video FAILED", "err", err)
+			}
+			return reaperFailed
+		}
+		log.Info("Re-enqueued stale video", "attempts", attempts, "max_attempts", r.s.cfg.Reaper.MaxAttempts)
+		return reaperRequeued
+	}
+	log.Warn("Marked stale video FAILED", "attempts", attempts, "max_attempts", r.s.cfg.Reaper.MaxAttempts)
+	return reaperFailed
+}
+
+// failJob records that the job stopped reporting, unless it already
+// reached a final state.
+func (r *reaper) failJob(ctx context.Context, job db.TranscodingJob) {
+	switch db.JobStatus(fmt.Sprint(job.Status)) {
+	case db.JobStatusSUCCESS, db.JobStatusFAILED:
+		return
+	}
+	message := fmt.Sprintf("no progress reported for %s, reaped", r.s.cfg.Reaper.StaleAfter)
+	_, err := r.s.store.UpdateTranscodingJobStatus(ctx, db.UpdateTranscodingJobStatusParams{
+		Status:       db.JobStatusFAILED,
+		ErrorMessage: pgtype.Text{String: message, Valid: true},
+		ID:           job.ID,
+		VideoID:      job.VideoID,
+	})
+	if err != nil {
+		r.s.log.Error(ErrFailedToUpdateJob, "job_id", job.ID, "err", err)
+	}
+}
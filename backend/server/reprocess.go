@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"gitlab.com/subrotokumar/playstack/libs/db"
+	"golang.org/x/time/rate"
+)
+
+// Statuses of reprocess_items. The videos counter is labelled with the
+// final ones in lower case.
+const (
+	reprocessPending  = "PENDING"
+	reprocessEnqueued = "ENQUEUED"
+	reprocessSkipped  = "SKIPPED"
+	reprocessFailed   = "FAILED"
+)
+
+// reprocessor sends the pending items of admin reprocess batches, paced by
+// one limiter shared by every batch. Items are claimed one at a time in a
+// transaction, so an item whose send was interrupted stays pending.
+type reprocessor struct {
+	s       *Server
+	limiter *rate.Limiter
+	videos  *prometheus.CounterVec
+	wake    chan struct{}
+}
+
+func newReprocessor(s *Server) *reprocessor {
+	videos := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "playstack_reprocess_videos_total",
+			Help: "Videos enqueued for re-transcoding by admin requests, partitioned by result.",
+		},
+		[]string{"result"},
+	)
+	if err := s.metrics.Register(videos); err != nil {
+		s.log.Fatal(err.Error())
+	}
+	cfg := s.cfg.Reprocess
+	return &reprocessor{
+		s:       s,
+		limiter: rate.NewLimiter(rate.Limit(cfg.RatePerSec), max(cfg.Burst, 1)),
+		videos:  videos,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Wake makes Run look for pending items now instead of at its next poll.
+func (r *reprocessor) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends pending items until ctx is cancelled, polling every
+// Reprocess.PollInterval for batches created by other replicas, and deletes
+// finished batches once they are older than Reprocess.Retention.
+func (r *reprocessor) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.s.cfg.Reprocess.PollInterval)
+	defer ticker.Stop()
+	for {
+		for r.next(ctx) {
+		}
+		if err := r.s.store.DeleteFinishedReprocessBatches(ctx, interval(r.s.cfg.Reprocess.Retention)); err != nil && ctx.Err() == nil {
+			r.s.log.Warn("Failed to delete finished reprocess batches", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// next waits on the limiter and sends the oldest pending item. It reports
+// whether an item was handled, so false means none is pending or the item
+// could not be claimed right now.
+func (r *reprocessor) next(ctx context.Context) bool {
+	if err := r.limiter.Wait(ctx); err != nil {
+		return false
+	}
+	var result string
+	err := r.s.store.ExecTx(ctx, func(q *db.Queries) error {
+		item, err := q.ClaimReprocessItem(ctx)
+		if err != nil {
+			return err
+		}
+		log := r.s.log.With("batch_id", item.BatchID, "video_id", item.VideoID)
+
+		var cause error
+		result = reprocessEnqueued
+		video, err := q.GetVideoByID(ctx, item.VideoID)
+		switch {
+		case err != nil:
+			return fmt.Errorf("get video: %w", err)
+		case video.Status == db.VideoStatusPREUPLOAD || video.Status == db.VideoStatusPROCESSING:
+			result, cause = reprocessSkipped, fmt.Errorf("video is %s", video.Status)
+		default:
+			if cause = r.s.enqueueTranscode(ctx, video, item.Packaging); cause != nil {
+				if ctx.Err() != nil {
+					return cause
+				}
+				result = reprocessFailed
+			}
+		}
+
+		params := db.FinishReprocessItemParams{Status: result, BatchID: item.BatchID, VideoID: item.VideoID}
+		if cause != nil {
+			params.Error = cause.Error()
+			log.Warn("Video not enqueued for reprocessing", "result", result, "err", cause)
+		} else {
+			log.Debug("Enqueued video for reprocessing")
+		}
+		return q.FinishReprocessItem(ctx, params)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			r.s.log.Error("Failed to send reprocess item", "err", err)
+		}
+		return false
+	}
+	r.videos.WithLabelValues(strings.ToLower(result)).Inc()
+	return true
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	_ "gitlab.com/subrotokumar/playstack/backend/swagger"
+	"gitlab.com/subrotokumar/playstack/libs/hmacauth"
 	"gitlab.com/subrotokumar/playstack/libs/idp"
 )
 
@@ -89,7 +90,7 @@ func (s *Server) registerOpenAPIRoutes(e *echo.Echo) {
 }
 
 func (s *Server) resisterMetricsRoutes(e *echo.Echo) {
-	customRegistry := prometheus.NewRegistry()
+	customRegistry := s.metrics
 	customCounter := prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "custom_requests_total",
@@ -113,6 +114,13 @@ func (s *Server) UserAuthMiddleware() echo.MiddlewareFunc {
 	return idp.NewAuthMiddleware(s.cfg.Aws.Region, s.cfg.Cognito.UserPoolID, s.cfg.Cognito.ClientID, s.log).AuthMiddleware()
 }
 
+func (s *Server) getHMACAuthMiddleware() echo.MiddlewareFunc {
+	if len(s.cfg.InternalAuth.Keys) == 0 {
+		s.log.Warn("INTERNAL_HMAC_KEYS is not set, internal callbacks will be rejected")
+	}
+	return hmacauth.NewVerifier(s.cfg.InternalAuth.Keys, s.cfg.InternalAuth.MaxSkew, nonceStore{s.store}).Middleware()
+}
+
 func (s *Server) getBasicAuthMiddleware() echo.MiddlewareFunc {
 	return middleware.BasicAuthWithConfig(middleware.BasicAuthConfig{
 		Realm: "restricted",
@@ -141,7 +149,8 @@ func (s *Server) getBasicAuthMiddleware() echo.MiddlewareFunc {
 
 func (s *Server) registerRoutes(e *echo.Echo) {
 	externalAuthMiddleware := s.UserAuthMiddleware()
-	internalAuthMiddleware := s.getBasicAuthMiddleware()
+	callbackAuthMiddleware := s.getHMACAuthMiddleware()
+	adminAuthMiddleware := s.getBasicAuthMiddleware()
 
 	e.GET("/health/liveness", s.LivenessHandler)
 	e.GET("/health/readiness", s.ReadinessHandler)
@@ -159,8 +168,30 @@ func (s *Server) registerRoutes(e *echo.Echo) {
 	mediaRoutes := e.Group("/media", externalAuthMiddleware)
 	mediaRoutes.GET("/videos", s.GetVideoHandler)
 	mediaRoutes.POST("/videos", s.VideoAssetsHandler)
+	mediaRoutes.GET("/videos/:videoId", s.GetVideoByIDHandler)
 	mediaRoutes.PUT("/videos/:videoId/thumbnail", s.ThumbnailSignedUrlHandler)
-
-	internal := e.Group("/internal", internalAuthMiddleware)
-	internal.PATCH("/media/videos/:videoId", s.UpdateMediaInternalHandler)
+	mediaRoutes.PUT("/videos/:videoId/poster", s.SelectPosterHandler)
+	mediaRoutes.GET("/videos/:videoId/outputs", s.PlaybackHandler)
+	mediaRoutes.GET("/videos/:videoId/jobs", s.ListJobsHandler)
+	mediaRoutes.GET("/videos/:videoId/key", s.GetHLSKeyHandler)
+	mediaRoutes.POST("/videos/:videoId/license", s.ClearKeyLicenseHandler)
+
+	// Tokenized HLS playback, for players that cannot send headers
+	playbackRoutes := e.Group("/playback")
+	playbackRoutes.GET("/:token/key", s.SignedHLSKeyHandler)
+	playbackRoutes.GET("/:token/*", s.SignedPlaylistHandler)
+
+	// Transcoder callbacks
+	callbacks := e.Group("/internal/media", callbackAuthMiddleware, s.idempotency.Middleware())
+	callbacks.PATCH("/videos/:videoId", s.UpdateMediaInternalHandler)
+	callbacks.PUT("/videos/:videoId/outputs", s.UpdateOutputsInternalHandler)
+	callbacks.POST("/videos/:videoId/jobs", s.CreateJobInternalHandler)
+	callbacks.PATCH("/videos/:videoId/jobs/:jobId", s.UpdateJobInternalHandler)
+	callbacks.POST("/videos/:videoId/heartbeat", s.HeartbeatInternalHandler)
+	callbacks.PUT("/videos/:videoId/keys", s.RegisterContentKeyInternalHandler)
+
+	// Operator routes
+	adminRoutes := e.Group("/admin", adminAuthMiddleware)
+	adminRoutes.POST("/videos/reprocess", s.ReprocessVideosHandler)
+	adminRoutes.GET("/videos/reprocess/:batchId", s.GetReprocessBatchHandler)
 }
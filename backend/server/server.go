@@ -1,16 +1,19 @@
 package server
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
 
 	validation "github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
 	"gitlab.com/subrotokumar/playstack/backend/config"
 	"gitlab.com/subrotokumar/playstack/libs/core"
 	"gitlab.com/subrotokumar/playstack/libs/db"
 	idp "gitlab.com/subrotokumar/playstack/libs/idp"
+	"gitlab.com/subrotokumar/playstack/libs/queue"
 	"gitlab.com/subrotokumar/playstack/libs/storage"
 )
 
@@ -34,6 +37,15 @@ type (
 		log     *core.Logger
 		store   *db.SQLStore
 		storage *storage.Storage
+		queue   *queue.Queue
+		metrics *prometheus.Registry
+		// idempotency deduplicates retried internal callbacks.
+		idempotency *idempotencyCache
+		reaper      *reaper
+		reprocessor *reprocessor
+		// keyring seals content keys; nil when CONTENT_KEKS is not set.
+		keyring  *keyring
+		playback *playbackSigner
 	}
 	Ctx struct {
 		echo.Context
@@ -60,13 +72,32 @@ func NewHTTPServer() *Server {
 
 	storage := storage.NewStorageProvider(cfg.Aws.Region)
 
+	keyring, err := newKeyring(cfg.ContentKeys.KEKs, cfg.ContentKeys.ActiveKEK)
+	if err != nil {
+		core.LogFatal("Invalid CONTENT_KEKS", "err", err.Error())
+	}
+	if keyring == nil {
+		logger.Warn("CONTENT_KEKS is not set, content keys can be neither stored nor served")
+	}
+	playback, ok := newPlaybackSigner(cfg.Playback.TokenSecret, cfg.Playback.TokenTTL)
+	if !ok {
+		logger.Warn("PLAYBACK_TOKEN_SECRET is not set, playback tokens are only valid on the replica that issued them")
+	}
+
 	srv := &Server{
-		cfg:     cfg,
-		idp:     idp.NewIndentityProvider(cfg.Aws.Region, cfg.Cognito.ClientID, cfg.Cognito.ClientSecret),
-		log:     logger,
-		store:   dbStore,
-		storage: storage,
+		cfg:         cfg,
+		idp:         idp.NewIndentityProvider(cfg.Aws.Region, cfg.Cognito.ClientID, cfg.Cognito.ClientSecret),
+		log:         logger,
+		store:       dbStore,
+		storage:     storage,
+		queue:       queue.NewMessageQueue(cfg.Aws.Region, logger),
+		metrics:     prometheus.NewRegistry(),
+		idempotency: newIdempotencyCache(dbStore, logger, cfg.Idempotency.TTL),
+		keyring:     keyring,
+		playback:    playback,
 	}
+	srv.reaper = newReaper(srv)
+	srv.reprocessor = newReprocessor(srv)
 	srv.handler = &http.Server{
 		Addr:    cfg.App.Host + ":" + cfg.App.Port,
 		Handler: srv.Mux(),
@@ -77,6 +108,13 @@ func NewHTTPServer() *Server {
 
 func (s *Server) Run() error {
 	defer s.store.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.cleanupCallbackState(ctx)
+	if s.cfg.Reaper.Enabled {
+		go s.reaper.Run(ctx)
+	}
+	go s.reprocessor.Run(ctx)
 	s.log.Info("Server running at " + s.cfg.App.Host + ":" + s.cfg.App.Port)
 	return s.handler.ListenAndServe()
 }
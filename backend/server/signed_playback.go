@@ -0,0 +1,305 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"gitlab.com/subrotokumar/playstack/libs/db"
+)
+
+const (
+	ErrInvalidPlaybackToken = "invalid or expired playback token"
+	ErrInvalidPlaybackPath  = "only HLS playlists are served through playback tokens"
+	ErrMediaBucketMissing   = "media bucket is not configured"
+	ErrFailedToFetchOutput  = "failed to fetch playlist"
+)
+
+// MIMEAppleMpegURL is the content type of HLS playlists.
+const MIMEAppleMpegURL = "application/vnd.apple.mpegurl"
+
+// A decoded playback token is the video ID, owner ID and expiry in Unix
+// seconds, followed by a truncated HMAC-SHA256 of them.
+const (
+	playbackTokenBody = 16 + 16 + 8
+	playbackTokenMAC  = 16
+)
+
+// uriAttribute matches the URI attribute of an HLS tag.
+var uriAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// playbackToken grants access to the HLS playlists and keys of one video
+// until Expires. It is carried in the URL path, so players that cannot add
+// an Authorization header, like AVPlayer, resolve relative playlist URIs
+// under it.
+type playbackToken struct {
+	VideoID uuid.UUID
+	OwnerID uuid.UUID
+	Expires time.Time
+}
+
+// playbackSigner signs and verifies playback tokens.
+type playbackSigner struct {
+	key []byte
+	ttl time.Duration
+}
+
+// newPlaybackSigner returns a signer keyed by secret. Without a secret it
+// uses a random key, so tokens are only valid on the replica that issued
+// them until it restarts.
+func newPlaybackSigner(secret string, ttl time.Duration) (*playbackSigner, bool) {
+	if secret != "" {
+		return &playbackSigner{key: []byte(secret), ttl: ttl}, true
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return &playbackSigner{key: key, ttl: ttl}, false
+}
+
+func (ps *playbackSigner) sign(t playbackToken) string {
+	buf := make([]byte, playbackTokenBody, playbackTokenBody+playbackTokenMAC)
+	copy(buf, t.VideoID[:])
+	copy(buf[16:], t.OwnerID[:])
+	binary.BigEndian.PutUint64(buf[32:], uint64(t.Expires.Unix()))
+	return base64.RawURLEncoding.EncodeToString(append(buf, ps.mac(buf)...))
+}
+
+func (ps *playbackSigner) verify(token string, now time.Time) (playbackToken, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != playbackTokenBody+playbackTokenMAC {
+		return playbackToken{}, false
+	}
+	body, mac := raw[:playbackTokenBody], raw[playbackTokenBody:]
+	if !hmac.Equal(mac, ps.mac(body)) {
+		return playbackToken{}, false
+	}
+	t := playbackToken{
+		VideoID: uuid.UUID(body[:16]),
+		OwnerID: uuid.UUID(body[16:32]),
+		Expires: time.Unix(int64(binary.BigEndian.Uint64(body[32:])), 0),
+	}
+	if !now.Before(t.Expires) {
+		return playbackToken{}, false
+	}
+	return t, true
+}
+
+func (ps *playbackSigner) mac(body []byte) []byte {
+	h := hmac.New(sha256.New, ps.key)
+	h.Write(body)
+	return h.Sum(nil)[:playbackTokenMAC]
+}
+
+// outputRoot is the media bucket prefix every output of a video lives
+// under.
+func outputRoot(ownerID, videoID uuid.UUID) string {
+	return "videos/" + ownerID.String() + "/" + videoID.String() + "/"
+}
+
+// signedHLSURL returns the tokenized URL of the video's HLS master playlist,
+// or "" when the video publishes no HLS.
+func (s *Server) signedHLSURL(c echo.Context, video db.Video, manifests []db.Manifest) (string, time.Time) {
+	root := outputRoot(video.UserID, video.ID)
+	for _, m := range manifests {
+		if m.Type != "HLS" || !strings.HasPrefix(m.S3Key, root) {
+			continue
+		}
+		expires := time.Now().Add(s.playback.ttl).Truncate(time.Second)
+		token := s.playback.sign(playbackToken{VideoID: video.ID, OwnerID: video.UserID, Expires: expires})
+		return c.Scheme() + "://" + c.Request().Host + "/playback/" + token + "/" + strings.TrimPrefix(m.S3Key, root), expires
+	}
+	return "", time.Time{}
+}
+
+// SignedPlaylistHandler godoc
+//
+// @Summary      Get an HLS playlist with a playback token
+// @Description Serves an HLS playlist of the video the token was issued for, as returned in hls_url by the outputs endpoint. Media playlists are rewritten so segments point at presigned media bucket URLs and EXT-X-KEY at the tokenized key endpoint, so players that cannot send an Authorization header can play encrypted HLS. Both expire with the token.
+// @Tags         Media
+// @Produce      application/vnd.apple.mpegurl
+// @Param        token  path      string  true  "Playback token"
+// @Param        path   path      string  true  "Playlist path under the video"
+// @Success      200    {file}    binary
+// @Failure      400    {object}  ContentKeyResponse
+// @Failure      403    {object}  ContentKeyResponse
+// @Failure      404    {object}  ContentKeyResponse
+// @Failure      500    {object}  ContentKeyResponse
+// @Failure      503    {object}  ContentKeyResponse
+// @Router       /playback/{token}/{path} [get]
+func (s *Server) SignedPlaylistHandler(c echo.Context) error {
+	token, ok := s.playback.verify(c.Param("token"), time.Now())
+	if !ok {
+		return c.JSON(http.StatusForbidden, ContentKeyResponse{Error: ErrInvalidPlaybackToken})
+	}
+	name := path.Clean("/" + c.Param("*"))[1:]
+	if path.Ext(name) != ".m3u8" {
+		return c.JSON(http.StatusBadRequest, ContentKeyResponse{Error: ErrInvalidPlaybackPath})
+	}
+	if s.cfg.S3.MediaBucket == "" {
+		return c.JSON(http.StatusServiceUnavailable, ContentKeyResponse{Error: ErrMediaBucketMissing})
+	}
+
+	ctx := c.Request().Context()
+	key := outputRoot(token.OwnerID, token.VideoID) + name
+	out, err := s.storage.Client().GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.S3.MediaBucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return c.JSON(http.StatusNotFound, ContentKeyResponse{Error: ErrVideoNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToFetchOutput, "key", key, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchOutput})
+	}
+	playlist, err := io.ReadAll(out.Body)
+	out.Body.Close()
+	if err != nil {
+		s.log.Error(ErrFailedToFetchOutput, "key", key, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchOutput})
+	}
+
+	keyURL := c.Scheme() + "://" + c.Request().Host + "/playback/" + c.Param("token") + "/key"
+	rewritten, err := s.rewritePlaylist(c, playlist, path.Dir(key), keyURL, token.Expires)
+	if err != nil {
+		s.log.Error(ErrFailedToFetchOutput, "key", key, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchOutput})
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Blob(http.StatusOK, MIMEAppleMpegURL, rewritten)
+}
+
+// rewritePlaylist points the segment and init segment URIs of an HLS
+// playlist in dir at presigned media bucket URLs valid until expires, and
+// the EXT-X-KEY URI at keyURL with the original kid. Playlist URIs stay
+// relative, so players fetch them through the same token.
+func (s *Server) rewritePlaylist(c echo.Context, playlist []byte, dir, keyURL string, expires time.Time) ([]byte, error) {
+	lifetime := int64(time.Until(expires).Seconds()) + 1
+	presign := func(uri string) (string, error) {
+		if path.Ext(uri) == ".m3u8" || strings.Contains(uri, "://") {
+			return uri, nil
+		}
+		req, err := s.storage.PresignedGetObjectUrl(c.Request().Context(), s.cfg.S3.MediaBucket, path.Join(dir, uri), lifetime)
+		if err != nil {
+			return "", err
+		}
+		return req.URL, nil
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var err error
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			line = uriAttribute.ReplaceAllStringFunc(line, func(attr string) string {
+				target := keyURL
+				if u, err := url.Parse(uriAttribute.FindStringSubmatch(attr)[1]); err == nil && u.Query().Has("kid") {
+					target += "?kid=" + url.QueryEscape(u.Query().Get("kid"))
+				}
+				return `URI="` + target + `"`
+			})
+		case strings.HasPrefix(line, "#"):
+			line = uriAttribute.ReplaceAllStringFunc(line, func(attr string) string {
+				uri, presignErr := presign(uriAttribute.FindStringSubmatch(attr)[1])
+				if presignErr != nil {
+					err = presignErr
+				}
+				return `URI="` + uri + `"`
+			})
+		case line != "":
+			line, err = presign(line)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), scanner.Err()
+}
+
+// SignedHLSKeyHandler godoc
+//
+// @Summary      Get the HLS decryption key with a playback token
+// @Description Returns the raw 16-byte AES-128 key with kid of the video the token was issued for. Rewritten media playlists point EXT-X-KEY here, so no Authorization header is needed.
+// @Tags         Media
+// @Produce      application/octet-stream
+// @Param        token  path      string  true   "Playback token"
+// @Param        kid    query     string  false  "Key ID"
+// @Success      200    {file}    binary
+// @Failure      400    {object}  ContentKeyResponse
+// @Failure      403    {object}  ContentKeyResponse
+// @Failure      404    {object}  ContentKeyResponse
+// @Failure      500    {object}  ContentKeyResponse
+// @Failure      503    {object}  ContentKeyResponse
+// @Router       /playback/{token}/key [get]
+func (s *Server) SignedHLSKeyHandler(c echo.Context) error {
+	if s.keyring == nil {
+		return c.JSON(http.StatusServiceUnavailable, ContentKeyResponse{Error: ErrContentKeysDisabled})
+	}
+	token, ok := s.playback.verify(c.Param("token"), time.Now())
+	if !ok {
+		return c.JSON(http.StatusForbidden, ContentKeyResponse{Error: ErrInvalidPlaybackToken})
+	}
+	return s.serveHLSKey(c, token.VideoID)
+}
+
+// serveHLSKey writes the AES-128 key of videoID selected by the kid query
+// parameter, or the most recently activated key without one.
+func (s *Server) serveHLSKey(c echo.Context, videoID uuid.UUID) error {
+	var keyID uuid.UUID
+	if kid := c.QueryParam("kid"); kid != "" {
+		var err error
+		if keyID, err = uuid.Parse(kid); err != nil {
+			return c.JSON(http.StatusBadRequest, ContentKeyResponse{Error: ErrInvalidHLSKeyID})
+		}
+	}
+
+	ctx := c.Request().Context()
+	var ck db.ContentKey
+	var err error
+	if keyID == uuid.Nil {
+		// Playlists written before key IDs were versioned carry no kid.
+		ck, err = s.store.GetCurrentContentKey(ctx, db.GetCurrentContentKeyParams{VideoID: videoID, Scheme: SchemeAES128})
+	} else {
+		ck, err = s.store.GetContentKey(ctx, db.GetContentKeyParams{VideoID: videoID, Scheme: SchemeAES128, KeyID: keyID})
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, ContentKeyResponse{Error: ErrContentKeyNotFound})
+	}
+	if err != nil {
+		s.log.Error(ErrFailedToFetchContentKey, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchContentKey})
+	}
+	key, err := s.keyring.open(ck)
+	if err != nil {
+		s.log.Error(ErrFailedToFetchContentKey, "video_id", videoID, "kek_id", ck.KekID, "err", err)
+		return c.JSON(http.StatusInternalServerError, ContentKeyResponse{Error: ErrFailedToFetchContentKey})
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Blob(http.StatusOK, echo.MIMEOctetStream, key)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"gitlab.com/subrotokumar/playstack/libs/db"
+	"gitlab.com/subrotokumar/playstack/libs/storage"
+)
+
+var ErrTranscodeQueueNotConfigured = errors.New("TRANSCODE_QUEUE_URL is not set")
+
+// packagingAttribute is the message attribute the transcoder reads the
+// packaging formats of a job from.
+const packagingAttribute = "packaging"
+
+// enqueueTranscode sends the transcoder the S3 event an upload of the
+// video's source would have produced, so the source is processed again.
+// packaging selects the manifest formats; when empty the transcoder default
+// applies.
+func (s *Server) enqueueTranscode(ctx context.Context, video db.Video, packaging []string) error {
+	if s.cfg.Queue.TranscodeURL == "" {
+		return ErrTranscodeQueueNotConfigured
+	}
+	key := video.SourceKey
+	head, err := s.storage.Client().HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.cfg.S3.RawMediaBucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("head source %s: %w", key, err)
+	}
+
+	event := storage.NewObjectCreatedEvent(s.cfg.Aws.Region, s.cfg.S3.RawMediaBucket, key, aws.ToInt64(head.ContentLength), aws.ToString(head.ETag))
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var attributes map[string]string
+	if len(packaging) > 0 {
+		attributes = map[string]string{packagingAttribute: strings.Join(packaging, ",")}
+	}
+	return s.queue.SendMessage(ctx, s.cfg.Queue.TranscodeURL, string(body), attributes)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// uploadFormat is a container accepted for raw uploads.
+type uploadFormat struct {
+	Extension   string
+	ContentType string
+	// Aliases are other MIME types browsers send for the container.
+	Aliases []string
+}
+
+// uploadFormats is the allow-list of source containers.
+var uploadFormats = []uploadFormat{
+	{Extension: ".mp4", ContentType: "video/mp4"},
+	{Extension: ".mov", ContentType: "video/quicktime"},
+	{Extension: ".mkv", ContentType: "video/x-matroska", Aliases: []string{"video/matroska"}},
+	{Extension: ".webm", ContentType: "video/webm"},
+	{Extension: ".avi", ContentType: "video/x-msvideo", Aliases: []string{"video/avi", "video/msvideo"}},
+}
+
+// lookupUploadFormat finds the format of an upload by its MIME type, or by
+// the extension of name when the type is missing or generic.
+func lookupUploadFormat(contentType, name string) (uploadFormat, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		for _, format := range uploadFormats {
+			if mediaType == format.ContentType {
+				return format, true
+			}
+			for _, alias := range format.Aliases {
+				if mediaType == alias {
+					return format, true
+				}
+			}
+		}
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, format := range uploadFormats {
+		if ext == format.Extension {
+			return format, true
+		}
+	}
+	return uploadFormat{}, false
+}
+
+// supportedUploadTypes lists the accepted MIME types for error messages.
+func supportedUploadTypes() string {
+	types := make([]string, 0, len(uploadFormats))
+	for _, format := range uploadFormats {
+		types = append(types, format.ContentType)
+	}
+	return strings.Join(types, ", ")
+}
+
+// sourceKey is the raw media bucket key a video source is uploaded to.
+func sourceKey(userID, videoID uuid.UUID, format uploadFormat) string {
+	return fmt.Sprintf("videos/%s/%s/video%s", userID.String(), videoID.String(), format.Extension)
+}
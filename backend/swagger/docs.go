@@ -22,6 +22,121 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/videos/reprocess": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Selects videos by ID or by status, owner and creation date and records them as a reprocess batch. A synthetic upload event is then sent for each, paced by REPROCESS_RATE_PER_SEC; the batch survives restarts and its progress is returned by GET /admin/videos/reprocess/{batchId}. With dry_run the selection is only returned.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Re-transcode videos (admin)",
+                "parameters": [
+                    {
+                        "description": "Video selection",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Dry run",
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/videos/reprocess/{batchId}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Returns a reprocess batch with the status of each of its videos: PENDING until its upload event is sent, then ENQUEUED, or SKIPPED or FAILED with the reason in error. Finished batches are deleted after REPROCESS_RETENTION.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get reprocess batch progress (admin)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "batchId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessBatchResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessBatchResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ReprocessBatchResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/health/liveness": {
             "get": {
                 "description": "Indicates whether the application process is alive",
@@ -66,10 +181,10 @@ const docTemplate = `{
             "patch": {
                 "security": [
                     {
-                        "BasicAuth": []
+                        "HMACSignature": []
                     }
                 ],
-                "description": "Updates title, status, or duration of a video",
+                "description": "Updates title, status, duration, processing progress or failure reason of a video",
                 "consumes": [
                     "application/json"
                 ],
@@ -120,43 +235,72 @@ const docTemplate = `{
                 }
             }
         },
-        "/media/videos": {
-            "get": {
+        "/internal/media/videos/{videoId}/heartbeat": {
+            "post": {
                 "security": [
                     {
-                        "BearerAuth": []
+                        "HMACSignature": []
                     }
                 ],
-                "description": "Returns videos with READY status",
+                "description": "Refreshes updated_at of a PROCESSING video so the stale job reaper leaves it alone. Sent periodically through every stage of a job.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Media"
+                    "Internal"
+                ],
+                "summary": "Report a live transcoder (internal)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Job owner",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.HeartbeatRequest"
+                        }
+                    }
                 ],
-                "summary": "List ready videos",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/server.GetVideoResponse"
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.JobResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/server.GetVideoResponse"
+                            "$ref": "#/definitions/server.JobResponse"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/internal/media/videos/{videoId}/jobs": {
             "post": {
                 "security": [
                     {
-                        "BearerAuth": []
+                        "HMACSignature": []
                     }
                 ],
-                "description": "Creates a video record and returns a presigned POST URL for uploading raw media",
+                "description": "Records a new PENDING processing attempt for a video, with the video status it started from as prior_status",
                 "consumes": [
                     "application/json"
                 ],
@@ -164,50 +308,63 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Media"
+                    "Internal"
                 ],
-                "summary": "Create presigned URL for video upload",
+                "summary": "Start a transcoding job (internal)",
                 "parameters": [
                     {
-                        "description": "Video asset metadata",
+                        "type": "string",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Job owner",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/server.AssetsRequest"
+                            "$ref": "#/definitions/server.CreateJobRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/server.AssetsResponse"
+                            "$ref": "#/definitions/server.JobResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/server.AssetsResponse"
+                            "$ref": "#/definitions/server.JobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.JobResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/server.AssetsResponse"
+                            "$ref": "#/definitions/server.JobResponse"
                         }
                     }
                 }
             }
         },
-        "/media/videos/{videoId}/thumbnail": {
-            "put": {
+        "/internal/media/videos/{videoId}/jobs/{jobId}": {
+            "patch": {
                 "security": [
                     {
-                        "BearerAuth": []
+                        "HMACSignature": []
                     }
                 ],
-                "description": "Returns a presigned PUT URL for uploading a video thumbnail",
+                "description": "Moves a job to a new state and records the failure reason",
                 "consumes": [
                     "application/json"
                 ],
@@ -215,9 +372,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Media"
+                    "Internal"
                 ],
-                "summary": "Create presigned URL for thumbnail upload",
+                "summary": "Update a transcoding job (internal)",
                 "parameters": [
                     {
                         "type": "string",
@@ -227,12 +384,19 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Thumbnail metadata",
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "jobId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Job state",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/server.ThumbnailAssetsRequest"
+                            "$ref": "#/definitions/server.UpdateJobRequest"
                         }
                     }
                 ],
@@ -240,33 +404,38 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/server.ThumbnailAssetsResponse"
+                            "$ref": "#/definitions/server.JobResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/server.AssetsResponse"
+                            "$ref": "#/definitions/server.JobResponse"
                         }
                     },
-                    "403": {
-                        "description": "Forbidden",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/server.AssetsResponse"
+                            "$ref": "#/definitions/server.JobResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/server.AssetsResponse"
+                            "$ref": "#/definitions/server.JobResponse"
                         }
                     }
                 }
             }
         },
-        "/profile": {
-            "get": {
-                "description": "Get Profile Detail",
+        "/internal/media/videos/{videoId}/keys": {
+            "put": {
+                "security": [
+                    {
+                        "HMACSignature": []
+                    }
+                ],
+                "description": "Seals a key the video's media is encrypted with and stores it next to the keys of earlier jobs. It is served once the transcoder reports outputs encrypted with it. The key is never returned.",
                 "consumes": [
                     "application/json"
                 ],
@@ -274,113 +443,226 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Profile"
+                    "Internal"
+                ],
+                "summary": "Register a content key (internal)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Content key",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyRequest"
+                        }
+                    }
                 ],
-                "summary": "Profile",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.ContentKeyResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Create a new user account",
+            }
+        },
+        "/internal/media/videos/{videoId}/outputs": {
+            "put": {
+                "security": [
+                    {
+                        "HMACSignature": []
+                    }
+                ],
+                "description": "Replaces the renditions and manifests recorded for a video and activates the content keys they are encrypted with",
                 "consumes": [
-                    "application/x-www-form-urlencoded"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Profile"
+                    "Internal"
                 ],
-                "summary": "Sign up a new user",
+                "summary": "Store transcoder outputs (internal)",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Name",
-                        "name": "name",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Email",
-                        "name": "email",
-                        "in": "formData",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Password",
-                        "name": "password",
-                        "in": "formData",
-                        "required": true
+                        "description": "Renditions and manifests",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.UpdateOutputsRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.PlaybackResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.PlaybackResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.PlaybackResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.PlaybackResponse"
                         }
                     }
                 }
             }
         },
-        "/profile/sessions": {
-            "post": {
-                "description": "Authenticate a user and set access/refresh cookies",
-                "consumes": [
-                    "application/x-www-form-urlencoded"
-                ],
-                "produces": [
-                    "application/json"
+        "/media/videos": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns videos with READY status",
+                "produces": [
+                    "application/json"
                 ],
                 "tags": [
-                    "Profile"
+                    "Media"
                 ],
-                "summary": "Login user",
+                "summary": "List ready videos",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.GetVideoResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.GetVideoResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates a video record and returns a presigned PUT URL for uploading raw media. The container is taken from content_type, or from the extension of name when the type is generic; MP4, MOV, MKV, WebM and AVI are accepted. The URL is signed for content_type exactly as declared, so the PUT must send that Content-Type, as returned in header.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "Create presigned URL for video upload",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User email",
-                        "name": "email",
-                        "in": "formData",
-                        "required": true
+                        "description": "Video asset metadata",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.AssetsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.AssetsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.AssetsResponse"
+                        }
                     },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.AssetsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/media/videos/{videoId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns a video, including the failure reason of a rejected upload. Other users only see published videos.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "Get a video",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "User password",
-                        "name": "password",
-                        "in": "formData",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
                         "required": true
                     }
                 ],
@@ -388,129 +670,243 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.VideoResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.VideoResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.VideoResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.VideoResponse"
                         }
                     }
                 }
             }
         },
-        "/profile/tokens": {
-            "post": {
-                "description": "Refresh access token using refresh token cookie",
-                "consumes": [
-                    "application/json"
+        "/media/videos/{videoId}/jobs": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Returns every processing attempt of a video, newest first",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Profile"
+                    "Media"
+                ],
+                "summary": "List transcoding jobs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Refresh access token",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.ListJobsResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.ListJobsResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.ListJobsResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ListJobsResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.ListJobsResponse"
                         }
                     }
                 }
             }
         },
-        "/profile/verifications": {
-            "post": {
-                "description": "Resend confirmation code (OTP) to user's email",
-                "consumes": [
-                    "application/x-www-form-urlencoded"
+        "/media/videos/{videoId}/key": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Returns the raw 16-byte AES-128 key referenced by EXT-X-KEY in the video's HLS media playlists, to viewers allowed to watch the video. Without kid, the most recently activated key is returned. Players that cannot send an Authorization header use hls_url from the outputs endpoint instead.",
                 "produces": [
-                    "application/json"
+                    "application/octet-stream"
                 ],
                 "tags": [
-                    "Profile"
+                    "Media"
                 ],
-                "summary": "Resend OTP",
+                "summary": "Get the HLS decryption key",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User email",
-                        "name": "email",
-                        "in": "formData",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key ID",
+                        "name": "kid",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "type": "file"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
                         }
                     }
                 }
             }
         },
-        "/profile/verifications/confirm": {
+        "/media/videos/{videoId}/license": {
             "post": {
-                "description": "Confirm a user's signup using OTP",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Answers a W3C ClearKey license request for the video's CENC-encrypted DASH output with the requested keys as a JWK set, to viewers allowed to watch the video. Key IDs are looked up among the keys of every job that published the video, so manifests of earlier jobs keep playing. Key IDs the video does not use are ignored; 404 is returned when none match.",
                 "consumes": [
-                    "application/x-www-form-urlencoded"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Profile"
+                    "Media"
                 ],
-                "summary": "Confirm signup",
+                "summary": "Get a ClearKey license",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User email",
-                        "name": "email",
-                        "in": "formData",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
                         "required": true
                     },
+                    {
+                        "description": "License request",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.ClearKeyLicenseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.ClearKeyLicense"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/media/videos/{videoId}/outputs": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the manifests and renditions of a video, and a tokenized HLS master playlist URL for players that cannot send an Authorization header",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "Get playable outputs",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "OTP code",
-                        "name": "otp",
-                        "in": "formData",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
                         "required": true
                     }
                 ],
@@ -518,193 +914,1324 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.PlaybackResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.PlaybackResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.PlaybackResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/server.AuthResponse"
+                            "$ref": "#/definitions/server.PlaybackResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "db.Video": {
-            "type": "object",
-            "properties": {
+        },
+        "/media/videos/{videoId}/poster": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Makes one of the generated thumbnail candidates the video poster",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "Select video poster",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Thumbnail candidate key",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.SelectPosterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.SelectPosterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.SelectPosterResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.SelectPosterResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.SelectPosterResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/media/videos/{videoId}/thumbnail": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns a presigned PUT URL for uploading a video thumbnail",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "Create presigned URL for thumbnail upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Video ID",
+                        "name": "videoId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Thumbnail metadata",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.ThumbnailAssetsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.ThumbnailAssetsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.AssetsResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.AssetsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.AssetsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/playback/{token}/key": {
+            "get": {
+                "description": "Returns the raw 16-byte AES-128 key with kid of the video the token was issued for. Rewritten media playlists point EXT-X-KEY here, so no Authorization header is needed.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "Get the HLS decryption key with a playback token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Playback token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key ID",
+                        "name": "kid",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/playback/{token}/{path}": {
+            "get": {
+                "description": "Serves an HLS playlist of the video the token was issued for, as returned in hls_url by the outputs endpoint. Media playlists are rewritten so segments point at presigned media bucket URLs and EXT-X-KEY at the tokenized key endpoint, so players that cannot send an Authorization header can play encrypted HLS. Both expire with the token.",
+                "produces": [
+                    "application/vnd.apple.mpegurl"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "Get an HLS playlist with a playback token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Playback token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Playlist path under the video",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.ContentKeyResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/profile": {
+            "get": {
+                "description": "Get Profile Detail",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Profile",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new user account",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Sign up a new user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name",
+                        "name": "name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Email",
+                        "name": "email",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/profile/sessions": {
+            "post": {
+                "description": "Authenticate a user and set access/refresh cookies",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Login user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User email",
+                        "name": "email",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/profile/tokens": {
+            "post": {
+                "description": "Refresh access token using refresh token cookie",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Refresh access token",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/profile/verifications": {
+            "post": {
+                "description": "Resend confirmation code (OTP) to user's email",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Resend OTP",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User email",
+                        "name": "email",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/profile/verifications/confirm": {
+            "post": {
+                "description": "Confirm a user's signup using OTP",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Confirm signup",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User email",
+                        "name": "email",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "OTP code",
+                        "name": "otp",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.AuthResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "db.JobStatus": {
+            "type": "string",
+            "enum": [
+                "PENDING",
+                "RUNNING",
+                "SUCCESS",
+                "FAILED"
+            ],
+            "x-enum-varnames": [
+                "JobStatusPENDING",
+                "JobStatusRUNNING",
+                "JobStatusSUCCESS",
+                "JobStatusFAILED"
+            ]
+        },
+        "db.Manifest": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "$ref": "#/definitions/pgtype.Timestamp"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "s3_key": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "video_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.ReprocessBatch": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "$ref": "#/definitions/pgtype.Timestamp"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "packaging": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "db.ReprocessItem": {
+            "type": "object",
+            "properties": {
+                "batch_id": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "$ref": "#/definitions/pgtype.Timestamp"
+                },
+                "video_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.TranscodingJob": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "$ref": "#/definitions/pgtype.Timestamp"
+                },
+                "error_message": {
+                    "$ref": "#/definitions/pgtype.Text"
+                },
+                "finished_at": {
+                    "$ref": "#/definitions/pgtype.Timestamp"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ladder": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "prior_status": {
+                    "$ref": "#/definitions/pgtype.Text"
+                },
+                "queue_managed": {
+                    "type": "boolean"
+                },
+                "started_at": {
+                    "$ref": "#/definitions/pgtype.Timestamp"
+                },
+                "status": {},
+                "updated_at": {
+                    "$ref": "#/definitions/pgtype.Timestamp"
+                },
+                "video_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.Video": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "$ref": "#/definitions/pgtype.Timestamp"
+                },
+                "duration_sec": {
+                    "$ref": "#/definitions/pgtype.Int4"
+                },
+                "failure_reason": {
+                    "$ref": "#/definitions/pgtype.Text"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "poster_key": {
+                    "$ref": "#/definitions/pgtype.Text"
+                },
+                "progress": {
+                    "type": "integer"
+                },
+                "source_key": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/db.VideoStatus"
+                },
+                "thumbnail_keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "$ref": "#/definitions/pgtype.Timestamp"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.VideoRendition": {
+            "type": "object",
+            "properties": {
+                "bitrate_kbps": {
+                    "type": "integer"
+                },
+                "codec": {
+                    "type": "string"
+                },
                 "created_at": {
                     "$ref": "#/definitions/pgtype.Timestamp"
                 },
-                "duration_sec": {
-                    "$ref": "#/definitions/pgtype.Int4"
+                "id": {
+                    "type": "string"
+                },
+                "resolution": {},
+                "s3_key": {
+                    "type": "string"
+                },
+                "video_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.VideoStatus": {
+            "type": "string",
+            "enum": [
+                "PREUPLOAD",
+                "UPLOADED",
+                "PROCESSING",
+                "FAILED",
+                "READY",
+                "PRIVATE",
+                "PUBLIC"
+            ],
+            "x-enum-varnames": [
+                "VideoStatusPREUPLOAD",
+                "VideoStatusUPLOADED",
+                "VideoStatusPROCESSING",
+                "VideoStatusFAILED",
+                "VideoStatusREADY",
+                "VideoStatusPRIVATE",
+                "VideoStatusPUBLIC"
+            ]
+        },
+        "pgtype.InfinityModifier": {
+            "type": "integer",
+            "format": "int32",
+            "enum": [
+                1,
+                0,
+                -1
+            ],
+            "x-enum-varnames": [
+                "Infinity",
+                "Finite",
+                "NegativeInfinity"
+            ]
+        },
+        "pgtype.Int4": {
+            "type": "object",
+            "properties": {
+                "int32": {
+                    "type": "integer",
+                    "format": "int32"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "pgtype.Text": {
+            "type": "object",
+            "properties": {
+                "string": {
+                    "type": "string"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "pgtype.Timestamp": {
+            "type": "object",
+            "properties": {
+                "infinityModifier": {
+                    "$ref": "#/definitions/pgtype.InfinityModifier"
+                },
+                "time": {
+                    "description": "Time zone will be ignored when encoding to PostgreSQL.",
+                    "type": "string"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "server.Asset": {
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "type": "string"
+                },
+                "href": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "original_name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.AssetsRequest": {
+            "type": "object",
+            "required": [
+                "content_type",
+                "name",
+                "size"
+            ],
+            "properties": {
+                "content_type": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.AssetsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/server.AssetsResponseData"
+                },
+                "error": {},
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.AssetsResponseData": {
+            "type": "object",
+            "properties": {
+                "asset": {
+                    "$ref": "#/definitions/server.Asset"
                 },
-                "id": {
+                "form": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "header": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "upload_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.AuthResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/server.Profile"
+                },
+                "error": {},
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.ClearKeyJWK": {
+            "type": "object",
+            "properties": {
+                "k": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.ClearKeyLicense": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.ClearKeyJWK"
+                    }
+                },
+                "type": {
                     "type": "string"
+                }
+            }
+        },
+        "server.ClearKeyLicenseRequest": {
+            "type": "object",
+            "required": [
+                "kids"
+            ],
+            "properties": {
+                "kids": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "temporary",
+                        "persistent-license"
+                    ]
+                }
+            }
+        },
+        "server.ContentKeyData": {
+            "type": "object",
+            "properties": {
+                "key_id": {
+                    "type": "string"
+                },
+                "scheme": {
+                    "type": "string"
+                },
+                "video_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.ContentKeyRequest": {
+            "type": "object",
+            "required": [
+                "key_id",
+                "scheme",
+                "user_id"
+            ],
+            "properties": {
+                "key": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "key_id": {
+                    "type": "string"
+                },
+                "scheme": {
+                    "type": "string",
+                    "enum": [
+                        "AES-128",
+                        "CENC"
+                    ]
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.ContentKeyResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/server.ContentKeyData"
+                },
+                "error": {},
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.CreateJobRequest": {
+            "type": "object",
+            "required": [
+                "user_id"
+            ],
+            "properties": {
+                "queue_managed": {
+                    "description": "QueueManaged is set when SQS redelivers the source message if\nthe worker dies, so the reaper does not enqueue another copy.",
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.DatabaseHealthStatus": {
+            "type": "object",
+            "properties": {
+                "acquired_conns": {
+                    "type": "integer"
+                },
+                "idle_conns": {
+                    "type": "integer"
+                },
+                "max_conns": {
+                    "type": "integer"
                 },
                 "status": {
-                    "$ref": "#/definitions/db.VideoStatus"
+                    "$ref": "#/definitions/server.Status"
                 },
-                "title": {
+                "total_conns": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.GetVideoResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.Video"
+                    }
+                },
+                "error": {},
+                "message": {
                     "type": "string"
+                }
+            }
+        },
+        "server.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "db": {
+                    "$ref": "#/definitions/server.DatabaseHealthStatus"
                 },
+                "status": {
+                    "$ref": "#/definitions/server.Status"
+                }
+            }
+        },
+        "server.HeartbeatRequest": {
+            "type": "object",
+            "required": [
+                "user_id"
+            ],
+            "properties": {
                 "user_id": {
                     "type": "string"
                 }
             }
         },
-        "db.VideoStatus": {
-            "type": "string",
-            "enum": [
-                "PREUPLOAD",
-                "UPLOADED",
-                "PROCESSING",
-                "FAILED",
-                "READY",
-                "PRIVATE",
-                "PUBLIC"
+        "server.JobLadder": {
+            "type": "object",
+            "required": [
+                "method"
             ],
-            "x-enum-varnames": [
-                "VideoStatusPREUPLOAD",
-                "VideoStatusUPLOADED",
-                "VideoStatusPROCESSING",
-                "VideoStatusFAILED",
-                "VideoStatusREADY",
-                "VideoStatusPRIVATE",
-                "VideoStatusPUBLIC"
-            ]
+            "properties": {
+                "fallback": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string",
+                    "enum": [
+                        "fixed",
+                        "per_title"
+                    ]
+                },
+                "rungs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.JobLadderRung"
+                    }
+                }
+            }
         },
-        "pgtype.InfinityModifier": {
-            "type": "integer",
-            "format": "int32",
-            "enum": [
-                1,
-                0,
-                -1
+        "server.JobLadderRung": {
+            "type": "object",
+            "required": [
+                "name"
             ],
-            "x-enum-varnames": [
-                "Infinity",
-                "Finite",
-                "NegativeInfinity"
-            ]
+            "properties": {
+                "bitrate_kbps": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "codec": {
+                    "type": "string"
+                },
+                "configured_kbps": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "crf": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "resolution": {
+                    "type": "string"
+                },
+                "trials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/server.JobLadderTrial"
+                    }
+                }
+            }
+        },
+        "server.JobLadderTrial": {
+            "type": "object",
+            "properties": {
+                "bitrate_kbps": {
+                    "type": "integer"
+                },
+                "crf": {
+                    "type": "integer"
+                },
+                "psnr": {
+                    "type": "number"
+                },
+                "ssim": {
+                    "type": "number"
+                }
+            }
+        },
+        "server.JobResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/db.TranscodingJob"
+                },
+                "error": {},
+                "message": {
+                    "type": "string"
+                }
+            }
         },
-        "pgtype.Int4": {
+        "server.ListJobsResponse": {
             "type": "object",
             "properties": {
-                "int32": {
-                    "type": "integer",
-                    "format": "int32"
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.TranscodingJob"
+                    }
                 },
-                "valid": {
-                    "type": "boolean"
+                "error": {},
+                "message": {
+                    "type": "string"
                 }
             }
         },
-        "pgtype.Timestamp": {
+        "server.ManifestRequest": {
             "type": "object",
+            "required": [
+                "s3_key",
+                "type"
+            ],
             "properties": {
-                "infinityModifier": {
-                    "$ref": "#/definitions/pgtype.InfinityModifier"
-                },
-                "time": {
-                    "description": "Time zone will be ignored when encoding to PostgreSQL.",
+                "s3_key": {
                     "type": "string"
                 },
-                "valid": {
-                    "type": "boolean"
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "DASH",
+                        "HLS",
+                        "THUMBNAILS"
+                    ]
                 }
             }
         },
-        "server.Asset": {
+        "server.PlaybackData": {
             "type": "object",
             "properties": {
-                "content_type": {
+                "hls_url": {
+                    "description": "HLSURL is the tokenized master playlist, playable without an\nAuthorization header until HLSURLExpiresAt.",
                     "type": "string"
                 },
-                "href": {
+                "hls_url_expires_at": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "string"
+                "manifests": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.Manifest"
+                    }
                 },
-                "name": {
-                    "type": "string"
+                "renditions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.VideoRendition"
+                    }
+                }
+            }
+        },
+        "server.PlaybackResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/server.PlaybackData"
                 },
-                "original_name": {
+                "error": {},
+                "message": {
                     "type": "string"
-                },
-                "size": {
-                    "type": "integer"
                 }
             }
         },
-        "server.AssetsRequest": {
+        "server.Profile": {
             "type": "object",
-            "required": [
-                "content_type",
-                "name",
-                "size"
-            ],
             "properties": {
-                "content_type": {
+                "email": {
                     "type": "string"
                 },
                 "name": {
                     "type": "string"
                 },
-                "size": {
-                    "type": "integer"
+                "sub": {
+                    "type": "string"
                 }
             }
         },
-        "server.AssetsResponse": {
+        "server.RenditionRequest": {
             "type": "object",
+            "required": [
+                "bitrate_kbps",
+                "resolution",
+                "s3_key"
+            ],
             "properties": {
-                "data": {
-                    "$ref": "#/definitions/server.AssetsResponseData"
+                "bitrate_kbps": {
+                    "type": "integer"
                 },
-                "error": {},
-                "message": {
+                "codec": {
+                    "description": "Codec is the video codec family. Renditions reported without it\nare H.264.",
+                    "type": "string",
+                    "enum": [
+                        "h264",
+                        "hevc",
+                        "av1"
+                    ]
+                },
+                "resolution": {
+                    "type": "string",
+                    "enum": [
+                        "240p",
+                        "360p",
+                        "480p",
+                        "720p",
+                        "1080p",
+                        "1440p",
+                        "2160p"
+                    ]
+                },
+                "s3_key": {
                     "type": "string"
                 }
             }
         },
-        "server.AssetsResponseData": {
+        "server.ReprocessBatchData": {
             "type": "object",
             "properties": {
-                "asset": {
-                    "$ref": "#/definitions/server.Asset"
+                "batch": {
+                    "$ref": "#/definitions/db.ReprocessBatch"
                 },
-                "form": {
+                "counts": {
                     "type": "object",
                     "additionalProperties": {
-                        "type": "string"
+                        "type": "integer"
                     }
                 },
-                "header": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "string"
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.ReprocessItem"
                     }
-                },
-                "upload_url": {
-                    "type": "string"
                 }
             }
         },
-        "server.AuthResponse": {
+        "server.ReprocessBatchResponse": {
             "type": "object",
             "properties": {
                 "data": {
-                    "$ref": "#/definitions/server.Profile"
+                    "$ref": "#/definitions/server.ReprocessBatchData"
                 },
                 "error": {},
                 "message": {
@@ -712,62 +2239,101 @@ const docTemplate = `{
                 }
             }
         },
-        "server.DatabaseHealthStatus": {
+        "server.ReprocessData": {
             "type": "object",
             "properties": {
-                "acquired_conns": {
-                    "type": "integer"
-                },
-                "idle_conns": {
-                    "type": "integer"
+                "batch_id": {
+                    "description": "BatchID identifies the batch the videos were added to. It is\nunset for a dry run.",
+                    "type": "string"
                 },
-                "max_conns": {
+                "count": {
                     "type": "integer"
                 },
-                "status": {
-                    "$ref": "#/definitions/server.Status"
+                "dry_run": {
+                    "type": "boolean"
                 },
-                "total_conns": {
-                    "type": "integer"
+                "video_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "server.GetVideoResponse": {
+        "server.ReprocessRequest": {
             "type": "object",
             "properties": {
-                "data": {
+                "created_after": {
+                    "type": "string"
+                },
+                "created_before": {
+                    "type": "string"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "description": "Limit caps the number of videos selected, up to\nREPROCESS_MAX_VIDEOS.",
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "packaging": {
+                    "description": "Packaging selects the manifest formats to publish. The\ntranscoder default is used when it is empty.",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/db.Video"
+                        "type": "string"
                     }
                 },
-                "error": {},
-                "message": {
+                "status": {
+                    "enum": [
+                        "UPLOADED",
+                        "READY",
+                        "FAILED",
+                        "PRIVATE",
+                        "PUBLIC"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/db.VideoStatus"
+                        }
+                    ]
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "video_id": {
                     "type": "string"
                 }
             }
         },
-        "server.HealthResponse": {
+        "server.ReprocessResponse": {
             "type": "object",
             "properties": {
-                "db": {
-                    "$ref": "#/definitions/server.DatabaseHealthStatus"
+                "data": {
+                    "$ref": "#/definitions/server.ReprocessData"
                 },
-                "status": {
-                    "$ref": "#/definitions/server.Status"
+                "error": {},
+                "message": {
+                    "type": "string"
                 }
             }
         },
-        "server.Profile": {
+        "server.SelectPosterRequest": {
             "type": "object",
+            "required": [
+                "key"
+            ],
             "properties": {
-                "email": {
-                    "type": "string"
-                },
-                "name": {
+                "key": {
                     "type": "string"
-                },
-                "sub": {
+                }
+            }
+        },
+        "server.SelectPosterResponse": {
+            "type": "object",
+            "properties": {
+                "error": {},
+                "message": {
                     "type": "string"
                 }
             }
@@ -822,12 +2388,60 @@ const docTemplate = `{
                 }
             }
         },
+        "server.UpdateJobRequest": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "error_message": {
+                    "type": "string"
+                },
+                "ladder": {
+                    "description": "Ladder is how the encoded ladder was chosen, recorded for\nauditing.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/server.JobLadder"
+                        }
+                    ]
+                },
+                "status": {
+                    "enum": [
+                        "PENDING",
+                        "RUNNING",
+                        "SUCCESS",
+                        "FAILED"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/db.JobStatus"
+                        }
+                    ]
+                }
+            }
+        },
         "server.UpdateMetadataRequest": {
             "type": "object",
             "properties": {
                 "duration_sec": {
                     "type": "integer"
                 },
+                "failure_reason": {
+                    "description": "FailureReason is the rejection code stored with a status change,\ne.g. NO_VIDEO_STREAM. It is cleared by a status change without one.",
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "metadata": {
+                    "$ref": "#/definitions/server.VideoMetadata"
+                },
+                "poster_key": {
+                    "type": "string"
+                },
+                "progress": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0
+                },
                 "status": {
                     "enum": [
                         "PREUPLOAD",
@@ -842,6 +2456,12 @@ const docTemplate = `{
                         }
                     ]
                 },
+                "thumbnail_keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "title": {
                     "type": "string"
                 },
@@ -849,6 +2469,86 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "server.UpdateOutputsRequest": {
+            "type": "object",
+            "required": [
+                "manifests",
+                "renditions",
+                "user_id"
+            ],
+            "properties": {
+                "key_ids": {
+                    "description": "KeyIDs are the content keys the outputs are encrypted with. They\nare activated together with the manifests that reference them.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "manifests": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/server.ManifestRequest"
+                    }
+                },
+                "renditions": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/server.RenditionRequest"
+                    }
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.VideoMetadata": {
+            "type": "object",
+            "properties": {
+                "audio_channels": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "audio_codec": {
+                    "type": "string"
+                },
+                "bitrate_kbps": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "container": {
+                    "type": "string"
+                },
+                "fps": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "height": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "video_codec": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "server.VideoResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/db.Video"
+                },
+                "error": {},
+                "message": {
+                    "type": "string"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -859,6 +2559,12 @@ const docTemplate = `{
             "type": "apiKey",
             "name": "Authorization",
             "in": "header"
+        },
+        "HMACSignature": {
+            "description": "HMAC-SHA256 request signature, sent with X-Signature-Key-Id, X-Signature-Timestamp and X-Signature-Nonce",
+            "type": "apiKey",
+            "name": "X-Signature",
+            "in": "header"
         }
     },
     "externalDocs": {
@@ -0,0 +1,12 @@
+package db
+
+// JobStatus mirrors the job_status enum. sqlc types the column as
+// interface{} because the enum is created inside a DO block.
+type JobStatus string
+
+const (
+	JobStatusPENDING JobStatus = "PENDING"
+	JobStatusRUNNING JobStatus = "RUNNING"
+	JobStatusSUCCESS JobStatus = "SUCCESS"
+	JobStatusFAILED  JobStatus = "FAILED"
+)
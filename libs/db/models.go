@@ -6,6 +6,7 @@ package db
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -59,6 +60,26 @@ func (ns NullVideoStatus) Value() (driver.Value, error) {
 	return string(ns.VideoStatus), nil
 }
 
+type ContentKey struct {
+	VideoID      uuid.UUID        `json:"video_id"`
+	Scheme       string           `json:"scheme"`
+	KeyID        uuid.UUID        `json:"key_id"`
+	EncryptedKey []byte           `json:"encrypted_key"`
+	KekID        string           `json:"kek_id"`
+	CreatedAt    pgtype.Timestamp `json:"created_at"`
+	UpdatedAt    pgtype.Timestamp `json:"updated_at"`
+	ActivatedAt  pgtype.Timestamp `json:"activated_at"`
+}
+
+type IdempotencyKey struct {
+	Key         string           `json:"key"`
+	Done        bool             `json:"done"`
+	Status      int32            `json:"status"`
+	ContentType string           `json:"content_type"`
+	Body        []byte           `json:"body"`
+	ExpiresAt   pgtype.Timestamp `json:"expires_at"`
+}
+
 type Manifest struct {
 	ID        uuid.UUID        `json:"id"`
 	VideoID   uuid.UUID        `json:"video_id"`
@@ -67,6 +88,25 @@ type Manifest struct {
 	CreatedAt pgtype.Timestamp `json:"created_at"`
 }
 
+type ReprocessBatch struct {
+	ID        uuid.UUID        `json:"id"`
+	Packaging []string         `json:"packaging"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type ReprocessItem struct {
+	BatchID   uuid.UUID        `json:"batch_id"`
+	VideoID   uuid.UUID        `json:"video_id"`
+	Status    string           `json:"status"`
+	Error     string           `json:"error"`
+	UpdatedAt pgtype.Timestamp `json:"updated_at"`
+}
+
+type RequestNonce struct {
+	Nonce     string           `json:"nonce"`
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+}
+
 type TranscodingJob struct {
 	ID           uuid.UUID        `json:"id"`
 	VideoID      uuid.UUID        `json:"video_id"`
@@ -74,6 +114,12 @@ type TranscodingJob struct {
 	ErrorMessage pgtype.Text      `json:"error_message"`
 	CreatedAt    pgtype.Timestamp `json:"created_at"`
 	UpdatedAt    pgtype.Timestamp `json:"updated_at"`
+	Attempt      int32            `json:"attempt"`
+	StartedAt    pgtype.Timestamp `json:"started_at"`
+	FinishedAt   pgtype.Timestamp `json:"finished_at"`
+	Ladder       json.RawMessage  `json:"ladder"`
+	QueueManaged bool             `json:"queue_managed"`
+	PriorStatus  pgtype.Text      `json:"prior_status"`
 }
 
 type User struct {
@@ -84,12 +130,19 @@ type User struct {
 }
 
 type Video struct {
-	ID          uuid.UUID        `json:"id"`
-	UserID      uuid.UUID        `json:"user_id"`
-	Title       string           `json:"title"`
-	Status      VideoStatus      `json:"status"`
-	DurationSec pgtype.Int4      `json:"duration_sec"`
-	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	ID            uuid.UUID        `json:"id"`
+	UserID        uuid.UUID        `json:"user_id"`
+	Title         string           `json:"title"`
+	Status        VideoStatus      `json:"status"`
+	DurationSec   pgtype.Int4      `json:"duration_sec"`
+	CreatedAt     pgtype.Timestamp `json:"created_at"`
+	Progress      int16            `json:"progress"`
+	PosterKey     pgtype.Text      `json:"poster_key"`
+	ThumbnailKeys []string         `json:"thumbnail_keys"`
+	Metadata      json.RawMessage  `json:"metadata"`
+	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+	FailureReason pgtype.Text      `json:"failure_reason"`
+	SourceKey     string           `json:"source_key"`
 }
 
 type VideoRendition struct {
@@ -99,4 +152,5 @@ type VideoRendition struct {
 	BitrateKbps int32            `json:"bitrate_kbps"`
 	S3Key       string           `json:"s3_key"`
 	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	Codec       string           `json:"codec"`
 }
@@ -8,30 +8,62 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	ActivateContentKeys(ctx context.Context, arg ActivateContentKeysParams) (int64, error)
+	AddReprocessItems(ctx context.Context, arg AddReprocessItemsParams) error
+	ClaimReprocessItem(ctx context.Context) (ClaimReprocessItemRow, error)
 	CountVideosByStatus(ctx context.Context) ([]CountVideosByStatusRow, error)
 	CountVideosByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	CreateReprocessBatch(ctx context.Context, arg CreateReprocessBatchParams) error
+	CreateTranscodingJob(ctx context.Context, arg CreateTranscodingJobParams) (TranscodingJob, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
 	CreateVideo(ctx context.Context, arg CreateVideoParams) (Video, error)
+	CreateVideoRendition(ctx context.Context, arg CreateVideoRenditionParams) (VideoRendition, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) error
+	DeleteExpiredRequestNonces(ctx context.Context) error
+	DeleteFinishedReprocessBatches(ctx context.Context, retention pgtype.Interval) error
 	DeleteUser(ctx context.Context, id uuid.UUID) error
 	DeleteVideo(ctx context.Context, id uuid.UUID) error
+	DeleteVideoRenditions(ctx context.Context, videoID uuid.UUID) error
+	FinishIdempotencyKey(ctx context.Context, arg FinishIdempotencyKeyParams) error
+	FinishReprocessItem(ctx context.Context, arg FinishReprocessItemParams) error
+	GetContentKey(ctx context.Context, arg GetContentKeyParams) (ContentKey, error)
+	GetCurrentContentKey(ctx context.Context, arg GetCurrentContentKeyParams) (ContentKey, error)
+	GetIdempotencyKey(ctx context.Context, key string) (IdempotencyKey, error)
+	GetReprocessBatch(ctx context.Context, id uuid.UUID) (ReprocessBatch, error)
 	GetTimestamp(ctx context.Context) (interface{}, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
 	GetVideoByID(ctx context.Context, id uuid.UUID) (Video, error)
+	GetVideoByIDForUpdate(ctx context.Context, id uuid.UUID) (Video, error)
 	GetVideoWithUser(ctx context.Context, id uuid.UUID) (GetVideoWithUserRow, error)
-	ListStaleProcessingVideos(ctx context.Context) ([]Video, error)
+	ListContentKeysByKeyIDs(ctx context.Context, arg ListContentKeysByKeyIDsParams) ([]ContentKey, error)
+	ListManifests(ctx context.Context, videoID uuid.UUID) ([]Manifest, error)
+	ListReprocessItems(ctx context.Context, batchID uuid.UUID) ([]ReprocessItem, error)
+	ListReprocessableVideos(ctx context.Context, arg ListReprocessableVideosParams) ([]Video, error)
+	ListStaleProcessingVideos(ctx context.Context, staleAfter pgtype.Interval) ([]Video, error)
+	ListTranscodingJobs(ctx context.Context, videoID uuid.UUID) ([]TranscodingJob, error)
+	ListVideoRenditions(ctx context.Context, videoID uuid.UUID) ([]VideoRendition, error)
 	ListVideosByStatus(ctx context.Context, status VideoStatus) ([]Video, error)
 	ListVideosByUser(ctx context.Context, userID uuid.UUID) ([]Video, error)
 	ListVideosByUserPaginated(ctx context.Context, arg ListVideosByUserPaginatedParams) ([]Video, error)
 	ListVideosWithUsers(ctx context.Context) ([]ListVideosWithUsersRow, error)
 	PatchVideos(ctx context.Context, arg PatchVideosParams) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	ReserveIdempotencyKey(ctx context.Context, arg ReserveIdempotencyKeyParams) (int64, error)
 	SearchVideo(ctx context.Context, arg SearchVideoParams) ([]Video, error)
+	TouchProcessingVideo(ctx context.Context, arg TouchProcessingVideoParams) error
+	UpdateStaleVideoStatus(ctx context.Context, arg UpdateStaleVideoStatusParams) (Video, error)
+	UpdateTranscodingJobStatus(ctx context.Context, arg UpdateTranscodingJobStatusParams) (TranscodingJob, error)
 	UpdateVideoDuration(ctx context.Context, arg UpdateVideoDurationParams) (Video, error)
 	UpdateVideoStatus(ctx context.Context, arg UpdateVideoStatusParams) (Video, error)
 	UpdateVideoTitle(ctx context.Context, arg UpdateVideoTitleParams) (Video, error)
+	UpsertContentKey(ctx context.Context, arg UpsertContentKeyParams) (ContentKey, error)
+	UpsertManifest(ctx context.Context, arg UpsertManifestParams) (Manifest, error)
+	UseRequestNonce(ctx context.Context, arg UseRequestNonceParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,43 @@
+-- name: UseRequestNonce :execrows
+INSERT INTO request_nonces (nonce, expires_at)
+VALUES (@nonce, now() + @ttl::interval)
+ON CONFLICT (nonce) DO UPDATE
+SET expires_at = EXCLUDED.expires_at
+WHERE request_nonces.expires_at < now();
+
+-- name: DeleteExpiredRequestNonces :exec
+DELETE FROM request_nonces
+WHERE expires_at < now();
+
+-- name: ReserveIdempotencyKey :execrows
+INSERT INTO idempotency_keys (key, expires_at)
+VALUES (@key, now() + @ttl::interval)
+ON CONFLICT (key) DO UPDATE
+SET done = false,
+    status = 0,
+    content_type = '',
+    body = NULL,
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at < now();
+
+-- name: GetIdempotencyKey :one
+SELECT *
+FROM idempotency_keys
+WHERE key = $1;
+
+-- name: FinishIdempotencyKey :exec
+UPDATE idempotency_keys
+SET done = true,
+    status = @status,
+    content_type = @content_type,
+    body = @body,
+    expires_at = now() + @ttl::interval
+WHERE key = @key;
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE key = $1 AND NOT done;
+
+-- name: DeleteExpiredIdempotencyKeys :exec
+DELETE FROM idempotency_keys
+WHERE expires_at < now();
@@ -0,0 +1,51 @@
+-- name: UpsertContentKey :one
+-- Stores a new key of a video. It is not served until it is activated.
+INSERT INTO content_keys (
+    video_id,
+    scheme,
+    key_id,
+    encrypted_key,
+    kek_id
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+ON CONFLICT (video_id, scheme, key_id) DO UPDATE
+SET encrypted_key = EXCLUDED.encrypted_key,
+    kek_id = EXCLUDED.kek_id,
+    updated_at = now()
+RETURNING *;
+
+-- name: ActivateContentKeys :execrows
+-- Activates the keys a job encrypted the outputs it reports with.
+UPDATE content_keys ck
+SET activated_at = now(), updated_at = now()
+FROM videos v
+WHERE v.id = ck.video_id
+  AND v.user_id = @user_id
+  AND ck.video_id = @video_id
+  AND ck.key_id = ANY(@key_ids::uuid[])
+  AND ck.activated_at IS NULL;
+
+-- name: GetContentKey :one
+SELECT *
+FROM content_keys
+WHERE video_id = $1 AND scheme = $2 AND key_id = $3
+  AND activated_at IS NOT NULL;
+
+-- name: GetCurrentContentKey :one
+-- Returns the most recently activated key of the scheme.
+SELECT *
+FROM content_keys
+WHERE video_id = $1 AND scheme = $2
+  AND activated_at IS NOT NULL
+ORDER BY activated_at DESC
+LIMIT 1;
+
+-- name: ListContentKeysByKeyIDs :many
+-- Returns the activated keys of the scheme among key_ids.
+SELECT *
+FROM content_keys
+WHERE video_id = @video_id AND scheme = @scheme
+  AND key_id = ANY(@key_ids::uuid[])
+  AND activated_at IS NOT NULL
+ORDER BY activated_at;
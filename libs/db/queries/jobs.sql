@@ -0,0 +1,32 @@
+-- name: CreateTranscodingJob :one
+INSERT INTO transcoding_jobs (
+    id,
+    video_id,
+    status,
+    queue_managed,
+    prior_status,
+    attempt
+) VALUES (
+    $1, $2, $3, $4, $5,
+    (SELECT COALESCE(MAX(attempt), 0) + 1 FROM transcoding_jobs WHERE video_id = $2)
+)
+RETURNING *;
+
+-- name: UpdateTranscodingJobStatus :one
+UPDATE transcoding_jobs
+SET
+  status = @status,
+  error_message = COALESCE(sqlc.narg('error_message'), error_message),
+  ladder = COALESCE(sqlc.narg('ladder')::jsonb, ladder),
+  started_at = CASE WHEN @status = 'RUNNING' THEN now() ELSE started_at END,
+  finished_at = CASE WHEN @status IN ('SUCCESS', 'FAILED') THEN now() ELSE finished_at END,
+  updated_at = now()
+WHERE
+  id = @id AND video_id = @video_id
+RETURNING *;
+
+-- name: ListTranscodingJobs :many
+SELECT *
+FROM transcoding_jobs
+WHERE video_id = $1
+ORDER BY attempt DESC;
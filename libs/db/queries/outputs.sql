@@ -0,0 +1,42 @@
+-- name: CreateVideoRendition :one
+INSERT INTO video_renditions (
+    id,
+    video_id,
+    resolution,
+    bitrate_kbps,
+    s3_key,
+    codec
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: ListVideoRenditions :many
+SELECT *
+FROM video_renditions
+WHERE video_id = $1
+ORDER BY bitrate_kbps DESC;
+
+-- name: DeleteVideoRenditions :exec
+DELETE FROM video_renditions
+WHERE video_id = $1;
+
+-- name: UpsertManifest :one
+INSERT INTO manifests (
+    id,
+    video_id,
+    s3_key,
+    type
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (video_id, type) DO UPDATE
+SET s3_key = EXCLUDED.s3_key,
+    created_at = now()
+RETURNING *;
+
+-- name: ListManifests :many
+SELECT *
+FROM manifests
+WHERE video_id = $1
+ORDER BY type;
@@ -0,0 +1,47 @@
+-- name: CreateReprocessBatch :exec
+INSERT INTO reprocess_batches (id, packaging)
+VALUES (@id, @packaging);
+
+-- name: AddReprocessItems :exec
+INSERT INTO reprocess_items (batch_id, video_id)
+SELECT @batch_id::uuid, unnest(@video_ids::uuid[]);
+
+-- name: ClaimReprocessItem :one
+-- Locks the oldest pending item. Other replicas skip it until the
+-- transaction that claimed it ends, and it stays PENDING if that
+-- transaction is rolled back.
+SELECT i.batch_id, i.video_id, b.packaging
+FROM reprocess_items i
+JOIN reprocess_batches b ON b.id = i.batch_id
+WHERE i.status = 'PENDING'
+ORDER BY b.created_at, i.video_id
+LIMIT 1
+FOR UPDATE OF i SKIP LOCKED;
+
+-- name: FinishReprocessItem :exec
+UPDATE reprocess_items
+SET status = @status,
+    error = @error,
+    updated_at = now()
+WHERE batch_id = @batch_id AND video_id = @video_id;
+
+-- name: GetReprocessBatch :one
+SELECT *
+FROM reprocess_batches
+WHERE id = $1;
+
+-- name: ListReprocessItems :many
+SELECT *
+FROM reprocess_items
+WHERE batch_id = $1
+ORDER BY video_id;
+
+-- name: DeleteFinishedReprocessBatches :exec
+-- Deletes batches older than retention that have no pending items left.
+DELETE FROM reprocess_batches b
+WHERE b.created_at < now() - @retention::interval
+  AND NOT EXISTS (
+    SELECT 1
+    FROM reprocess_items i
+    WHERE i.batch_id = b.id AND i.status = 'PENDING'
+  );
@@ -4,9 +4,10 @@ INSERT INTO videos (
     user_id,
     title,
     status,
-    duration_sec
+    duration_sec,
+    source_key
 ) VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6
 )
 RETURNING *;
 
@@ -15,6 +16,12 @@ SELECT *
 FROM videos
 WHERE id = $1;
 
+-- name: GetVideoByIDForUpdate :one
+SELECT *
+FROM videos
+WHERE id = $1
+FOR UPDATE;
+
 -- name: ListVideosByUser :many
 SELECT *
 FROM videos
@@ -45,19 +52,19 @@ OFFSET COALESCE(sqlc.narg('page')::INT, 0) * COALESCE(sqlc.narg('size')::INT, 30
 
 -- name: UpdateVideoStatus :one
 UPDATE videos
-SET status = $2
+SET status = $2, updated_at = now()
 WHERE id = $1
 RETURNING *;
 
 -- name: UpdateVideoDuration :one
 UPDATE videos
-SET duration_sec = $2
+SET duration_sec = $2, updated_at = now()
 WHERE id = $1
 RETURNING *;
 
 -- name: UpdateVideoTitle :one
 UPDATE videos
-SET title = $2
+SET title = $2, updated_at = now()
 WHERE id = $1
 RETURNING *;
 
@@ -71,18 +78,66 @@ FROM videos
 WHERE status = $1
 ORDER BY created_at ASC;
 
+-- name: ListReprocessableVideos :many
+-- Lists videos whose source can be transcoded again: uploaded, and not in
+-- PROCESSING. Every filter is optional.
+SELECT *
+FROM videos
+WHERE
+    id      = COALESCE(sqlc.narg('id'), id)
+AND user_id = COALESCE(sqlc.narg('user_id'), user_id)
+AND status  = COALESCE(sqlc.narg('status'), status)
+AND status IN ('UPLOADED', 'READY', 'FAILED', 'PRIVATE', 'PUBLIC')
+AND (
+    sqlc.narg('created_after')::TIMESTAMP IS NULL
+    OR created_at >= sqlc.narg('created_after')
+)
+AND (
+    sqlc.narg('created_before')::TIMESTAMP IS NULL
+    OR created_at < sqlc.narg('created_before')
+)
+ORDER BY created_at ASC
+LIMIT sqlc.arg('max_results');
+
 -- name: ListStaleProcessingVideos :many
 SELECT *
 FROM videos
 WHERE status = 'PROCESSING'
-  AND created_at < now() - interval '30 minutes'
-ORDER BY created_at ASC;
+  AND updated_at < now() - sqlc.arg('stale_after')::interval
+ORDER BY updated_at ASC;
+
+-- name: TouchProcessingVideo :exec
+-- Refreshes updated_at of a PROCESSING video, so the stale job reaper
+-- leaves it alone while its worker is alive.
+UPDATE videos
+SET updated_at = now()
+WHERE id = @id AND user_id = @user_id AND status = 'PROCESSING';
 
 -- name: PatchVideos :exec
 UPDATE videos
 SET 
   title = COALESCE(sqlc.narg('title')::text, title),
   status = COALESCE(sqlc.narg('status'), status),
-  duration_sec = COALESCE(sqlc.narg('duration_sec'), duration_sec)
+  duration_sec = COALESCE(sqlc.narg('duration_sec'), duration_sec),
+  progress = COALESCE(sqlc.narg('progress'), progress),
+  poster_key = COALESCE(sqlc.narg('poster_key'), poster_key),
+  thumbnail_keys = COALESCE(sqlc.narg('thumbnail_keys')::text[], thumbnail_keys),
+  metadata = COALESCE(sqlc.narg('metadata')::jsonb, metadata),
+  -- A status change replaces the failure reason, clearing it when none is given.
+  failure_reason = CASE
+    WHEN sqlc.narg('status')::video_status IS NULL THEN failure_reason
+    ELSE sqlc.narg('failure_reason')::text
+  END,
+  updated_at = now()
 WHERE
-  id = @id AND user_id = @user_id;
\ No newline at end of file
+  id = @id AND user_id = @user_id;
+
+-- name: UpdateStaleVideoStatus :one
+-- Moves a stale PROCESSING video to status unless it reported progress
+-- since it was listed, so only one reaper acts on it.
+UPDATE videos
+SET status = @status, updated_at = now()
+WHERE id = @id
+  AND status = 'PROCESSING'
+  AND updated_at = @updated_at
+RETURNING *;
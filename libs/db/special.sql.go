@@ -7,6 +7,7 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -69,7 +70,7 @@ func (q *Queries) GetTimestamp(ctx context.Context) (interface{}, error) {
 
 const getVideoWithUser = `-- name: GetVideoWithUser :one
 SELECT
-    v.id, v.user_id, v.title, v.status, v.duration_sec, v.created_at,
+    v.id, v.user_id, v.title, v.status, v.duration_sec, v.created_at, v.progress, v.poster_key, v.thumbnail_keys, v.metadata, v.updated_at, v.failure_reason, v.source_key,
     u.email
 FROM videos v
 JOIN users u ON u.id = v.user_id
@@ -77,13 +78,20 @@ WHERE v.id = $1
 `
 
 type GetVideoWithUserRow struct {
-	ID          uuid.UUID        `json:"id"`
-	UserID      uuid.UUID        `json:"user_id"`
-	Title       string           `json:"title"`
-	Status      VideoStatus      `json:"status"`
-	DurationSec pgtype.Int4      `json:"duration_sec"`
-	CreatedAt   pgtype.Timestamp `json:"created_at"`
-	Email       string           `json:"email"`
+	ID            uuid.UUID        `json:"id"`
+	UserID        uuid.UUID        `json:"user_id"`
+	Title         string           `json:"title"`
+	Status        VideoStatus      `json:"status"`
+	DurationSec   pgtype.Int4      `json:"duration_sec"`
+	CreatedAt     pgtype.Timestamp `json:"created_at"`
+	Progress      int16            `json:"progress"`
+	PosterKey     pgtype.Text      `json:"poster_key"`
+	ThumbnailKeys []string         `json:"thumbnail_keys"`
+	Metadata      json.RawMessage  `json:"metadata"`
+	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+	FailureReason pgtype.Text      `json:"failure_reason"`
+	SourceKey     string           `json:"source_key"`
+	Email         string           `json:"email"`
 }
 
 func (q *Queries) GetVideoWithUser(ctx context.Context, id uuid.UUID) (GetVideoWithUserRow, error) {
@@ -96,6 +104,13 @@ func (q *Queries) GetVideoWithUser(ctx context.Context, id uuid.UUID) (GetVideoW
 		&i.Status,
 		&i.DurationSec,
 		&i.CreatedAt,
+		&i.Progress,
+		&i.PosterKey,
+		&i.ThumbnailKeys,
+		&i.Metadata,
+		&i.UpdatedAt,
+		&i.FailureReason,
+		&i.SourceKey,
 		&i.Email,
 	)
 	return i, err
@@ -103,7 +118,7 @@ func (q *Queries) GetVideoWithUser(ctx context.Context, id uuid.UUID) (GetVideoW
 
 const listVideosWithUsers = `-- name: ListVideosWithUsers :many
 SELECT
-    v.id, v.user_id, v.title, v.status, v.duration_sec, v.created_at,
+    v.id, v.user_id, v.title, v.status, v.duration_sec, v.created_at, v.progress, v.poster_key, v.thumbnail_keys, v.metadata, v.updated_at, v.failure_reason, v.source_key,
     u.email
 FROM videos v
 JOIN users u ON u.id = v.user_id
@@ -111,13 +126,20 @@ ORDER BY v.created_at DESC
 `
 
 type ListVideosWithUsersRow struct {
-	ID          uuid.UUID        `json:"id"`
-	UserID      uuid.UUID        `json:"user_id"`
-	Title       string           `json:"title"`
-	Status      VideoStatus      `json:"status"`
-	DurationSec pgtype.Int4      `json:"duration_sec"`
-	CreatedAt   pgtype.Timestamp `json:"created_at"`
-	Email       string           `json:"email"`
+	ID            uuid.UUID        `json:"id"`
+	UserID        uuid.UUID        `json:"user_id"`
+	Title         string           `json:"title"`
+	Status        VideoStatus      `json:"status"`
+	DurationSec   pgtype.Int4      `json:"duration_sec"`
+	CreatedAt     pgtype.Timestamp `json:"created_at"`
+	Progress      int16            `json:"progress"`
+	PosterKey     pgtype.Text      `json:"poster_key"`
+	ThumbnailKeys []string         `json:"thumbnail_keys"`
+	Metadata      json.RawMessage  `json:"metadata"`
+	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+	FailureReason pgtype.Text      `json:"failure_reason"`
+	SourceKey     string           `json:"source_key"`
+	Email         string           `json:"email"`
 }
 
 func (q *Queries) ListVideosWithUsers(ctx context.Context) ([]ListVideosWithUsersRow, error) {
@@ -136,6 +158,13 @@ func (q *Queries) ListVideosWithUsers(ctx context.Context) ([]ListVideosWithUser
 			&i.Status,
 			&i.DurationSec,
 			&i.CreatedAt,
+			&i.Progress,
+			&i.PosterKey,
+			&i.ThumbnailKeys,
+			&i.Metadata,
+			&i.UpdatedAt,
+			&i.FailureReason,
+			&i.SourceKey,
 			&i.Email,
 		); err != nil {
 			return nil, err
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -43,3 +44,19 @@ func (store *SQLStore) Acquire(ctx context.Context) (c *pgxpool.Conn, err error)
 func (store *SQLStore) Stat() *pgxpool.Stat {
 	return store.connPool.Stat()
 }
+
+// ExecTx runs fn inside a transaction, committing if it returns nil and
+// rolling back otherwise.
+func (store *SQLStore) ExecTx(ctx context.Context, fn func(*Queries) error) error {
+	tx, err := store.connPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(store.WithTx(tx)); err != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			return fmt.Errorf("tx err: %w, rollback err: %v", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -7,6 +7,7 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -18,11 +19,12 @@ INSERT INTO videos (
     user_id,
     title,
     status,
-    duration_sec
+    duration_sec,
+    source_key
 ) VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6
 )
-RETURNING id, user_id, title, status, duration_sec, created_at
+RETURNING id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 `
 
 type CreateVideoParams struct {
@@ -31,6 +33,7 @@ type CreateVideoParams struct {
 	Title       string      `json:"title"`
 	Status      VideoStatus `json:"status"`
 	DurationSec pgtype.Int4 `json:"duration_sec"`
+	SourceKey   string      `json:"source_key"`
 }
 
 func (q *Queries) CreateVideo(ctx context.Context, arg CreateVideoParams) (Video, error) {
@@ -40,6 +43,7 @@ func (q *Queries) CreateVideo(ctx context.Context, arg CreateVideoParams) (Video
 		arg.Title,
 		arg.Status,
 		arg.DurationSec,
+		arg.SourceKey,
 	)
 	var i Video
 	err := row.Scan(
@@ -49,6 +53,13 @@ func (q *Queries) CreateVideo(ctx context.Context, arg CreateVideoParams) (Video
 		&i.Status,
 		&i.DurationSec,
 		&i.CreatedAt,
+		&i.Progress,
+		&i.PosterKey,
+		&i.ThumbnailKeys,
+		&i.Metadata,
+		&i.UpdatedAt,
+		&i.FailureReason,
+		&i.SourceKey,
 	)
 	return i, err
 }
@@ -64,7 +75,7 @@ func (q *Queries) DeleteVideo(ctx context.Context, id uuid.UUID) error {
 }
 
 const getVideoByID = `-- name: GetVideoByID :one
-SELECT id, user_id, title, status, duration_sec, created_at
+SELECT id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 FROM videos
 WHERE id = $1
 `
@@ -79,20 +90,127 @@ func (q *Queries) GetVideoByID(ctx context.Context, id uuid.UUID) (Video, error)
 		&i.Status,
 		&i.DurationSec,
 		&i.CreatedAt,
+		&i.Progress,
+		&i.PosterKey,
+		&i.ThumbnailKeys,
+		&i.Metadata,
+		&i.UpdatedAt,
+		&i.FailureReason,
+		&i.SourceKey,
 	)
 	return i, err
 }
 
+const getVideoByIDForUpdate = `-- name: GetVideoByIDForUpdate :one
+SELECT id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
+FROM videos
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetVideoByIDForUpdate(ctx context.Context, id uuid.UUID) (Video, error) {
+	row := q.db.QueryRow(ctx, getVideoByIDForUpdate, id)
+	var i Video
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Status,
+		&i.DurationSec,
+		&i.CreatedAt,
+		&i.Progress,
+		&i.PosterKey,
+		&i.ThumbnailKeys,
+		&i.Metadata,
+		&i.UpdatedAt,
+		&i.FailureReason,
+		&i.SourceKey,
+	)
+	return i, err
+}
+
+const listReprocessableVideos = `-- name: ListReprocessableVideos :many
+SELECT id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
+FROM videos
+WHERE
+    id      = COALESCE($1, id)
+AND user_id = COALESCE($2, user_id)
+AND status  = COALESCE($3, status)
+AND status IN ('UPLOADED', 'READY', 'FAILED', 'PRIVATE', 'PUBLIC')
+AND (
+    $4::TIMESTAMP IS NULL
+    OR created_at >= $4
+)
+AND (
+    $5::TIMESTAMP IS NULL
+    OR created_at < $5
+)
+ORDER BY created_at ASC
+LIMIT $6
+`
+
+type ListReprocessableVideosParams struct {
+	ID            pgtype.UUID      `json:"id"`
+	UserID        pgtype.UUID      `json:"user_id"`
+	Status        NullVideoStatus  `json:"status"`
+	CreatedAfter  pgtype.Timestamp `json:"created_after"`
+	CreatedBefore pgtype.Timestamp `json:"created_before"`
+	MaxResults    int32            `json:"max_results"`
+}
+
+// Lists videos whose source can be transcoded again: uploaded, and not in
+// PROCESSING. Every filter is optional.
+func (q *Queries) ListReprocessableVideos(ctx context.Context, arg ListReprocessableVideosParams) ([]Video, error) {
+	rows, err := q.db.Query(ctx, listReprocessableVideos,
+		arg.ID,
+		arg.UserID,
+		arg.Status,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Video{}
+	for rows.Next() {
+		var i Video
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.Status,
+			&i.DurationSec,
+			&i.CreatedAt,
+			&i.Progress,
+			&i.PosterKey,
+			&i.ThumbnailKeys,
+			&i.Metadata,
+			&i.UpdatedAt,
+			&i.FailureReason,
+			&i.SourceKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listStaleProcessingVideos = `-- name: ListStaleProcessingVideos :many
-SELECT id, user_id, title, status, duration_sec, created_at
+SELECT id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 FROM videos
 WHERE status = 'PROCESSING'
-  AND created_at < now() - interval '30 minutes'
-ORDER BY created_at ASC
+  AND updated_at < now() - $1::interval
+ORDER BY updated_at ASC
 `
 
-func (q *Queries) ListStaleProcessingVideos(ctx context.Context) ([]Video, error) {
-	rows, err := q.db.Query(ctx, listStaleProcessingVideos)
+func (q *Queries) ListStaleProcessingVideos(ctx context.Context, staleAfter pgtype.Interval) ([]Video, error) {
+	rows, err := q.db.Query(ctx, listStaleProcessingVideos, staleAfter)
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +225,13 @@ func (q *Queries) ListStaleProcessingVideos(ctx context.Context) ([]Video, error
 			&i.Status,
 			&i.DurationSec,
 			&i.CreatedAt,
+			&i.Progress,
+			&i.PosterKey,
+			&i.ThumbnailKeys,
+			&i.Metadata,
+			&i.UpdatedAt,
+			&i.FailureReason,
+			&i.SourceKey,
 		); err != nil {
 			return nil, err
 		}
@@ -119,7 +244,7 @@ func (q *Queries) ListStaleProcessingVideos(ctx context.Context) ([]Video, error
 }
 
 const listVideosByStatus = `-- name: ListVideosByStatus :many
-SELECT id, user_id, title, status, duration_sec, created_at
+SELECT id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 FROM videos
 WHERE status = $1
 ORDER BY created_at ASC
@@ -141,6 +266,13 @@ func (q *Queries) ListVideosByStatus(ctx context.Context, status VideoStatus) ([
 			&i.Status,
 			&i.DurationSec,
 			&i.CreatedAt,
+			&i.Progress,
+			&i.PosterKey,
+			&i.ThumbnailKeys,
+			&i.Metadata,
+			&i.UpdatedAt,
+			&i.FailureReason,
+			&i.SourceKey,
 		); err != nil {
 			return nil, err
 		}
@@ -153,7 +285,7 @@ func (q *Queries) ListVideosByStatus(ctx context.Context, status VideoStatus) ([
 }
 
 const listVideosByUser = `-- name: ListVideosByUser :many
-SELECT id, user_id, title, status, duration_sec, created_at
+SELECT id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 FROM videos
 WHERE user_id = $1
 ORDER BY created_at DESC
@@ -175,6 +307,13 @@ func (q *Queries) ListVideosByUser(ctx context.Context, userID uuid.UUID) ([]Vid
 			&i.Status,
 			&i.DurationSec,
 			&i.CreatedAt,
+			&i.Progress,
+			&i.PosterKey,
+			&i.ThumbnailKeys,
+			&i.Metadata,
+			&i.UpdatedAt,
+			&i.FailureReason,
+			&i.SourceKey,
 		); err != nil {
 			return nil, err
 		}
@@ -187,7 +326,7 @@ func (q *Queries) ListVideosByUser(ctx context.Context, userID uuid.UUID) ([]Vid
 }
 
 const listVideosByUserPaginated = `-- name: ListVideosByUserPaginated :many
-SELECT id, user_id, title, status, duration_sec, created_at
+SELECT id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 FROM videos
 WHERE user_id = $1
 ORDER BY created_at DESC
@@ -216,6 +355,13 @@ func (q *Queries) ListVideosByUserPaginated(ctx context.Context, arg ListVideosB
 			&i.Status,
 			&i.DurationSec,
 			&i.CreatedAt,
+			&i.Progress,
+			&i.PosterKey,
+			&i.ThumbnailKeys,
+			&i.Metadata,
+			&i.UpdatedAt,
+			&i.FailureReason,
+			&i.SourceKey,
 		); err != nil {
 			return nil, err
 		}
@@ -232,17 +378,32 @@ UPDATE videos
 SET 
   title = COALESCE($1::text, title),
   status = COALESCE($2, status),
-  duration_sec = COALESCE($3, duration_sec)
+  duration_sec = COALESCE($3, duration_sec),
+  progress = COALESCE($4, progress),
+  poster_key = COALESCE($5, poster_key),
+  thumbnail_keys = COALESCE($6::text[], thumbnail_keys),
+  metadata = COALESCE($7::jsonb, metadata),
+  -- A status change replaces the failure reason, clearing it when none is given.
+  failure_reason = CASE
+    WHEN $2::video_status IS NULL THEN failure_reason
+    ELSE $8::text
+  END,
+  updated_at = now()
 WHERE
-  id = $4 AND user_id = $5
+  id = $9 AND user_id = $10
 `
 
 type PatchVideosParams struct {
-	Title       pgtype.Text     `json:"title"`
-	Status      NullVideoStatus `json:"status"`
-	DurationSec pgtype.Int4     `json:"duration_sec"`
-	ID          uuid.UUID       `json:"id"`
-	UserID      uuid.UUID       `json:"user_id"`
+	Title         pgtype.Text     `json:"title"`
+	Status        NullVideoStatus `json:"status"`
+	DurationSec   pgtype.Int4     `json:"duration_sec"`
+	Progress      pgtype.Int2     `json:"progress"`
+	PosterKey     pgtype.Text     `json:"poster_key"`
+	ThumbnailKeys []string        `json:"thumbnail_keys"`
+	Metadata      json.RawMessage `json:"metadata"`
+	FailureReason pgtype.Text     `json:"failure_reason"`
+	ID            uuid.UUID       `json:"id"`
+	UserID        uuid.UUID       `json:"user_id"`
 }
 
 func (q *Queries) PatchVideos(ctx context.Context, arg PatchVideosParams) error {
@@ -250,6 +411,11 @@ func (q *Queries) PatchVideos(ctx context.Context, arg PatchVideosParams) error
 		arg.Title,
 		arg.Status,
 		arg.DurationSec,
+		arg.Progress,
+		arg.PosterKey,
+		arg.ThumbnailKeys,
+		arg.Metadata,
+		arg.FailureReason,
 		arg.ID,
 		arg.UserID,
 	)
@@ -257,7 +423,7 @@ func (q *Queries) PatchVideos(ctx context.Context, arg PatchVideosParams) error
 }
 
 const searchVideo = `-- name: SearchVideo :many
-SELECT id, user_id, title, status, duration_sec, created_at
+SELECT id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 FROM videos
 WHERE
     user_id = COALESCE($1, user_id)
@@ -301,6 +467,13 @@ func (q *Queries) SearchVideo(ctx context.Context, arg SearchVideoParams) ([]Vid
 			&i.Status,
 			&i.DurationSec,
 			&i.CreatedAt,
+			&i.Progress,
+			&i.PosterKey,
+			&i.ThumbnailKeys,
+			&i.Metadata,
+			&i.UpdatedAt,
+			&i.FailureReason,
+			&i.SourceKey,
 		); err != nil {
 			return nil, err
 		}
@@ -312,11 +485,67 @@ func (q *Queries) SearchVideo(ctx context.Context, arg SearchVideoParams) ([]Vid
 	return items, nil
 }
 
+const touchProcessingVideo = `-- name: TouchProcessingVideo :exec
+UPDATE videos
+SET updated_at = now()
+WHERE id = $1 AND user_id = $2 AND status = 'PROCESSING'
+`
+
+type TouchProcessingVideoParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// Refreshes updated_at of a PROCESSING video, so the stale job reaper
+// leaves it alone while its worker is alive.
+func (q *Queries) TouchProcessingVideo(ctx context.Context, arg TouchProcessingVideoParams) error {
+	_, err := q.db.Exec(ctx, touchProcessingVideo, arg.ID, arg.UserID)
+	return err
+}
+
+const updateStaleVideoStatus = `-- name: UpdateStaleVideoStatus :one
+UPDATE videos
+SET status = $1, updated_at = now()
+WHERE id = $2
+  AND status = 'PROCESSING'
+  AND updated_at = $3
+RETURNING id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
+`
+
+type UpdateStaleVideoStatusParams struct {
+	Status    VideoStatus      `json:"status"`
+	ID        uuid.UUID        `json:"id"`
+	UpdatedAt pgtype.Timestamp `json:"updated_at"`
+}
+
+// Moves a stale PROCESSING video to status unless it reported progress
+// since it was listed, so only one reaper acts on it.
+func (q *Queries) UpdateStaleVideoStatus(ctx context.Context, arg UpdateStaleVideoStatusParams) (Video, error) {
+	row := q.db.QueryRow(ctx, updateStaleVideoStatus, arg.Status, arg.ID, arg.UpdatedAt)
+	var i Video
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Status,
+		&i.DurationSec,
+		&i.CreatedAt,
+		&i.Progress,
+		&i.PosterKey,
+		&i.ThumbnailKeys,
+		&i.Metadata,
+		&i.UpdatedAt,
+		&i.FailureReason,
+		&i.SourceKey,
+	)
+	return i, err
+}
+
 const updateVideoDuration = `-- name: UpdateVideoDuration :one
 UPDATE videos
-SET duration_sec = $2
+SET duration_sec = $2, updated_at = now()
 WHERE id = $1
-RETURNING id, user_id, title, status, duration_sec, created_at
+RETURNING id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 `
 
 type UpdateVideoDurationParams struct {
@@ -334,15 +563,22 @@ func (q *Queries) UpdateVideoDuration(ctx context.Context, arg UpdateVideoDurati
 		&i.Status,
 		&i.DurationSec,
 		&i.CreatedAt,
+		&i.Progress,
+		&i.PosterKey,
+		&i.ThumbnailKeys,
+		&i.Metadata,
+		&i.UpdatedAt,
+		&i.FailureReason,
+		&i.SourceKey,
 	)
 	return i, err
 }
 
 const updateVideoStatus = `-- name: UpdateVideoStatus :one
 UPDATE videos
-SET status = $2
+SET status = $2, updated_at = now()
 WHERE id = $1
-RETURNING id, user_id, title, status, duration_sec, created_at
+RETURNING id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 `
 
 type UpdateVideoStatusParams struct {
@@ -360,15 +596,22 @@ func (q *Queries) UpdateVideoStatus(ctx context.Context, arg UpdateVideoStatusPa
 		&i.Status,
 		&i.DurationSec,
 		&i.CreatedAt,
+		&i.Progress,
+		&i.PosterKey,
+		&i.ThumbnailKeys,
+		&i.Metadata,
+		&i.UpdatedAt,
+		&i.FailureReason,
+		&i.SourceKey,
 	)
 	return i, err
 }
 
 const updateVideoTitle = `-- name: UpdateVideoTitle :one
 UPDATE videos
-SET title = $2
+SET title = $2, updated_at = now()
 WHERE id = $1
-RETURNING id, user_id, title, status, duration_sec, created_at
+RETURNING id, user_id, title, status, duration_sec, created_at, progress, poster_key, thumbnail_keys, metadata, updated_at, failure_reason, source_key
 `
 
 type UpdateVideoTitleParams struct {
@@ -386,6 +629,13 @@ func (q *Queries) UpdateVideoTitle(ctx context.Context, arg UpdateVideoTitlePara
 		&i.Status,
 		&i.DurationSec,
 		&i.CreatedAt,
+		&i.Progress,
+		&i.PosterKey,
+		&i.ThumbnailKeys,
+		&i.Metadata,
+		&i.UpdatedAt,
+		&i.FailureReason,
+		&i.SourceKey,
 	)
 	return i, err
 }
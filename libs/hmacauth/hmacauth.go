@@ -0,0 +1,83 @@
+// Package hmacauth signs and verifies service-to-service HTTP requests.
+//
+// A signed request carries the key ID, a Unix timestamp, a random nonce and
+// an HMAC-SHA256 over the method, path, timestamp, nonce and body digest:
+//
+//	METHOD \n /path?query \n timestamp \n nonce \n hex(sha256(body))
+//
+// Verifiers accept several keys at once so secrets can be rotated without
+// downtime: add the new key to the verifier, move signers over, then drop
+// the old key.
+package hmacauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderKeyID     = "X-Signature-Key-Id"
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderNonce     = "X-Signature-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+var ErrMissingKey = errors.New("hmacauth: signing key id and secret are required")
+
+// Signer signs outgoing requests with a single key.
+type Signer struct {
+	keyID  string
+	secret []byte
+}
+
+func NewSigner(keyID, secret string) (*Signer, error) {
+	if keyID == "" || secret == "" {
+		return nil, ErrMissingKey
+	}
+	return &Signer{keyID: keyID, secret: []byte(secret)}, nil
+}
+
+// Sign sets the signature headers on req for body, which must be the exact
+// bytes sent as the request body.
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(HeaderKeyID, s.keyID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, signature(s.secret, req.Method, req.URL.RequestURI(), timestamp, nonce, body))
+	return nil
+}
+
+// signature returns the hex HMAC-SHA256 of the canonical request.
+func signature(secret []byte, method, uri, timestamp, nonce string, body []byte) string {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{
+		method,
+		uri,
+		timestamp,
+		nonce,
+		hex.EncodeToString(digest[:]),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,149 @@
+package hmacauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// DefaultMaxSkew is how far a request timestamp may be from the
+	// verifier clock.
+	DefaultMaxSkew = 5 * time.Minute
+	// maxBodyBytes bounds the body read to verify a signature.
+	maxBodyBytes = 10 << 20
+)
+
+type AuthResponse struct {
+	Message string `json:"message,omitempty"`
+	Error   any    `json:"error,omitempty"`
+}
+
+// NonceStore records the nonces of verified requests. Use reports false
+// when nonce was already used; ttl is how long it must be remembered.
+// Verifiers behind a load balancer must share one store, or a captured
+// request can be replayed against another instance.
+type NonceStore interface {
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// Verifier checks signed requests against a set of active keys and
+// remembers nonces until their timestamp falls out of the skew window.
+type Verifier struct {
+	keys    map[string][]byte
+	maxSkew time.Duration
+	nonces  NonceStore
+}
+
+// NewVerifier accepts requests signed by any of keys, a map of key ID to
+// secret. A zero maxSkew uses DefaultMaxSkew. A nil nonces keeps nonces in
+// memory, which only protects a single instance.
+func NewVerifier(keys map[string]string, maxSkew time.Duration, nonces NonceStore) *Verifier {
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	if nonces == nil {
+		nonces = NewMemoryNonces()
+	}
+	v := &Verifier{
+		keys:    make(map[string][]byte, len(keys)),
+		maxSkew: maxSkew,
+		nonces:  nonces,
+	}
+	for id, secret := range keys {
+		if id != "" && secret != "" {
+			v.keys[id] = []byte(secret)
+		}
+	}
+	return v
+}
+
+// Middleware rejects requests with a missing or invalid signature, a
+// timestamp outside the skew window or a nonce that was already used. The
+// verified key ID is stored in the context as "hmac_key_id".
+func (v *Verifier) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			keyID := req.Header.Get(HeaderKeyID)
+			timestamp := req.Header.Get(HeaderTimestamp)
+			nonce := req.Header.Get(HeaderNonce)
+			sig := req.Header.Get(HeaderSignature)
+			if keyID == "" || timestamp == "" || nonce == "" || sig == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, AuthResponse{Error: "missing signature"})
+			}
+			secret, ok := v.keys[keyID]
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, AuthResponse{Error: "unknown signing key"})
+			}
+
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, AuthResponse{Error: "invalid signature timestamp"})
+			}
+			signedAt := time.Unix(unix, 0)
+			if skew := time.Since(signedAt); skew > v.maxSkew || skew < -v.maxSkew {
+				return echo.NewHTTPError(http.StatusUnauthorized, AuthResponse{Error: "signature expired"})
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, maxBodyBytes))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, AuthResponse{Error: "request body too large"})
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			expected := signature(secret, req.Method, req.URL.RequestURI(), timestamp, nonce, body)
+			if !hmac.Equal([]byte(expected), []byte(sig)) {
+				return echo.NewHTTPError(http.StatusUnauthorized, AuthResponse{Error: "invalid signature"})
+			}
+			// Only a valid signature may consume a nonce, so forged requests
+			// cannot burn nonces of real ones.
+			fresh, err := v.nonces.Use(req.Context(), keyID+":"+nonce, time.Until(signedAt.Add(v.maxSkew)))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusServiceUnavailable, AuthResponse{Error: "cannot check signature nonce"}).SetInternal(err)
+			}
+			if !fresh {
+				return echo.NewHTTPError(http.StatusUnauthorized, AuthResponse{Error: "replayed request"})
+			}
+
+			c.Set("hmac_key_id", keyID)
+			return next(c)
+		}
+	}
+}
+
+// MemoryNonces is a NonceStore for a single instance.
+type MemoryNonces struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func NewMemoryNonces() *MemoryNonces {
+	return &MemoryNonces{nonces: make(map[string]time.Time)}
+}
+
+// Use records nonce and reports false if it was seen before. Nonces are
+// forgotten once their ttl passed.
+func (m *MemoryNonces) Use(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for n, expires := range m.nonces {
+		if now.After(expires) {
+			delete(m.nonces, n)
+		}
+	}
+	if _, seen := m.nonces[nonce]; seen {
+		return false, nil
+	}
+	m.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
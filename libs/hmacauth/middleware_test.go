@@ -0,0 +1,140 @@
+package hmacauth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+	const body = `{"status":"READY"}`
+	keys := map[string]string{"old": "old-secret", "new": "new-secret"}
+
+	sign := func(t *testing.T, keyID, secret string) *http.Request {
+		t.Helper()
+		signer, err := NewSigner(keyID, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPatch, "/internal/media/videos/1?x=1", bytes.NewBufferString(body))
+		if err := signer.Sign(req, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name string
+		// requests are sent in order; want is the status of the last one.
+		requests func(t *testing.T) []*http.Request
+		want     int
+	}{
+		{
+			name: "valid signature",
+			requests: func(t *testing.T) []*http.Request {
+				return []*http.Request{sign(t, "new", "new-secret")}
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "rotation accepts the old key",
+			requests: func(t *testing.T) []*http.Request {
+				return []*http.Request{sign(t, "old", "old-secret")}
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "unknown key id",
+			requests: func(t *testing.T) []*http.Request {
+				return []*http.Request{sign(t, "retired", "old-secret")}
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "bad signature",
+			requests: func(t *testing.T) []*http.Request {
+				return []*http.Request{sign(t, "new", "old-secret")}
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered body",
+			requests: func(t *testing.T) []*http.Request {
+				req := sign(t, "new", "new-secret")
+				req.Body = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(`{"status":"FAILED"}`)).Body
+				return []*http.Request{req}
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "missing signature",
+			requests: func(t *testing.T) []*http.Request {
+				req := sign(t, "new", "new-secret")
+				req.Header.Del(HeaderSignature)
+				return []*http.Request{req}
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "stale timestamp",
+			requests: func(t *testing.T) []*http.Request {
+				req := sign(t, "new", "new-secret")
+				timestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+				req.Header.Set(HeaderTimestamp, timestamp)
+				req.Header.Set(HeaderSignature, signature([]byte("new-secret"), req.Method, req.URL.RequestURI(), timestamp, req.Header.Get(HeaderNonce), []byte(body)))
+				return []*http.Request{req}
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "replayed nonce",
+			requests: func(t *testing.T) []*http.Request {
+				req := sign(t, "new", "new-secret")
+				replay := req.Clone(req.Context())
+				replay.Body = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(body)).Body
+				return []*http.Request{req, replay}
+			},
+			want: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			verifier := NewVerifier(keys, time.Minute, nil)
+			e.PATCH("/internal/media/videos/:id", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, verifier.Middleware())
+
+			var got int
+			for _, req := range tt.requests(t) {
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				got = rec.Code
+			}
+			if got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryNoncesExpire(t *testing.T) {
+	nonces := NewMemoryNonces()
+	ctx := t.Context()
+	if fresh, _ := nonces.Use(ctx, "a", time.Millisecond); !fresh {
+		t.Fatal("first use of a nonce was rejected")
+	}
+	if fresh, _ := nonces.Use(ctx, "a", time.Millisecond); fresh {
+		t.Fatal("second use of a live nonce was accepted")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if fresh, _ := nonces.Use(ctx, "a", time.Millisecond); !fresh {
+		t.Fatal("expired nonce was not forgotten")
+	}
+}
@@ -11,9 +11,10 @@ import (
 func (actor Queue) GetMessages(ctx context.Context, queueUrl string, maxMessages int32, waitTime int32) ([]types.Message, error) {
 	var messages []types.Message
 	result, err := actor.SqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
-		QueueUrl:            aws.String(queueUrl),
-		MaxNumberOfMessages: maxMessages,
-		WaitTimeSeconds:     waitTime,
+		QueueUrl:              aws.String(queueUrl),
+		MaxNumberOfMessages:   maxMessages,
+		WaitTimeSeconds:       waitTime,
+		MessageAttributeNames: []string{"All"},
 	})
 	if err != nil {
 		actor.log.Debug("Couldn't get messages from queue %v. Here's why: %v\n", queueUrl, err)
@@ -33,3 +34,37 @@ func (actor Queue) DeleteMessage(ctx context.Context, queueUrl string, receiptHa
 	}
 	return err
 }
+
+func (actor Queue) ChangeMessageVisibility(ctx context.Context, queueUrl string, receiptHandle string, timeout int32) error {
+	_, err := actor.SqsClient.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(queueUrl),
+		ReceiptHandle:     aws.String(receiptHandle),
+		VisibilityTimeout: timeout,
+	})
+	if err != nil {
+		actor.log.Error("Failed to change visibility of message", "receipt_handle", receiptHandle, "err", err)
+	}
+	return err
+}
+
+// SendMessage sends body with optional string message attributes.
+func (actor Queue) SendMessage(ctx context.Context, queueUrl string, body string, attributes map[string]string) error {
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(queueUrl),
+		MessageBody: aws.String(body),
+	}
+	if len(attributes) > 0 {
+		input.MessageAttributes = make(map[string]types.MessageAttributeValue, len(attributes))
+		for name, value := range attributes {
+			input.MessageAttributes[name] = types.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(value),
+			}
+		}
+	}
+	_, err := actor.SqsClient.SendMessage(ctx, input)
+	if err != nil {
+		actor.log.Error("Failed to send message", "queue_url", queueUrl, "err", err)
+	}
+	return err
+}
@@ -23,5 +23,6 @@ func NewMessageQueue(region string, log *core.Logger) *Queue {
 	sqsClient := sqs.NewFromConfig(sdkConfig)
 	return &Queue{
 		SqsClient: sqsClient,
+		log:       *log,
 	}
 }
@@ -1,5 +1,35 @@
 package storage
 
-import "github.com/aws/aws-lambda-go/events"
+import (
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
 
 type S3Event = events.S3Event
+
+// NewObjectCreatedEvent builds the S3 notification an upload of key would
+// have produced, so an existing object can be queued for processing again.
+func NewObjectCreatedEvent(region, bucket, key string, size int64, etag string) S3Event {
+	return S3Event{
+		Records: []events.S3EventRecord{{
+			EventVersion: "2.1",
+			EventSource:  "aws:s3",
+			AWSRegion:    region,
+			EventTime:    time.Now().UTC(),
+			EventName:    "ObjectCreated:Put",
+			S3: events.S3Entity{
+				SchemaVersion: "1.0",
+				Bucket: events.S3Bucket{
+					Name: bucket,
+					Arn:  "arn:aws:s3:::" + bucket,
+				},
+				Object: events.S3Object{
+					Key:  key,
+					Size: size,
+					ETag: etag,
+				},
+			},
+		}},
+	}
+}
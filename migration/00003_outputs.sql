@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TYPE video_resolution ADD VALUE IF NOT EXISTS '1440p';
+ALTER TYPE video_resolution ADD VALUE IF NOT EXISTS '2160p';
+
+-- A video has one manifest per packaging type (DASH, HLS).
+ALTER TABLE manifests DROP CONSTRAINT IF EXISTS manifests_video_id_key;
+ALTER TABLE manifests ADD CONSTRAINT manifests_video_id_type_key UNIQUE (video_id, type);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE manifests DROP CONSTRAINT IF EXISTS manifests_video_id_type_key;
+DELETE FROM manifests a
+USING manifests b
+WHERE a.video_id = b.video_id AND a.created_at < b.created_at;
+ALTER TABLE manifests ADD CONSTRAINT manifests_video_id_key UNIQUE (video_id);
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE transcoding_jobs
+    ADD COLUMN IF NOT EXISTS attempt INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS started_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_video_id_attempt ON transcoding_jobs(video_id, attempt);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP INDEX IF EXISTS idx_jobs_video_id_attempt;
+
+ALTER TABLE transcoding_jobs
+    DROP COLUMN IF EXISTS finished_at,
+    DROP COLUMN IF EXISTS started_at,
+    DROP COLUMN IF EXISTS attempt;
+-- +goose StatementEnd
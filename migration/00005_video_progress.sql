@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS progress SMALLINT NOT NULL DEFAULT 0
+    CHECK (progress BETWEEN 0 AND 100);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE videos DROP COLUMN IF EXISTS progress;
+-- +goose StatementEnd
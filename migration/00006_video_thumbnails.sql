@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS poster_key TEXT,
+    ADD COLUMN IF NOT EXISTS thumbnail_keys TEXT[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE videos
+    DROP COLUMN IF EXISTS thumbnail_keys,
+    DROP COLUMN IF EXISTS poster_key;
+-- +goose StatementEnd
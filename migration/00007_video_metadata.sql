@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- Probe results reported by the transcoder: codecs, resolution, fps,
+-- bitrate, audio channels and container.
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE videos
+    DROP COLUMN IF EXISTS metadata;
+-- +goose StatementEnd
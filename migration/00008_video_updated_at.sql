@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- updated_at moves on every status or progress report, so a PROCESSING
+-- video whose updated_at stops moving has lost its worker.
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_videos_status_updated_at ON videos(status, updated_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP INDEX IF EXISTS idx_videos_status_updated_at;
+
+ALTER TABLE videos
+    DROP COLUMN IF EXISTS updated_at;
+-- +goose StatementEnd
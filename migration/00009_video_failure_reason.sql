@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- failure_reason is a machine-readable code, e.g. NO_VIDEO_STREAM, set when
+-- a video is rejected. It is cleared on the next status change.
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE videos
+    DROP COLUMN IF EXISTS failure_reason;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- source_key is the raw media bucket key of the upload. Its extension
+-- follows the uploaded container, e.g. video.mov.
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS source_key TEXT;
+
+UPDATE videos
+SET source_key = 'videos/' || user_id || '/' || id || '/video.mp4'
+WHERE source_key IS NULL;
+
+ALTER TABLE videos
+    ALTER COLUMN source_key SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE videos
+    DROP COLUMN IF EXISTS source_key;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- content_keys holds the per-video media encryption keys. The key itself is
+-- sealed with a backend key-encryption key (kek_id) and never stored in the
+-- media bucket.
+CREATE TABLE IF NOT EXISTS content_keys (
+    video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    scheme TEXT NOT NULL,
+    key_id UUID NOT NULL,
+    encrypted_key BYTEA NOT NULL,
+    kek_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (video_id, scheme)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS content_keys;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- codec tells apart renditions of the same resolution encoded with
+-- different video codecs: h264, hevc or av1.
+ALTER TABLE video_renditions
+    ADD COLUMN IF NOT EXISTS codec TEXT NOT NULL DEFAULT 'h264';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE video_renditions
+    DROP COLUMN IF EXISTS codec;
+-- +goose StatementEnd
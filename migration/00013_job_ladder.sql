@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- ladder records the renditions a job encoded and, for per-title
+-- encoding, the sample measurements each bitrate was chosen from.
+ALTER TABLE transcoding_jobs
+    ADD COLUMN IF NOT EXISTS ladder JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE transcoding_jobs
+    DROP COLUMN IF EXISTS ladder;
+-- +goose StatementEnd
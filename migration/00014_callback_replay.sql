@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- request_nonces remembers the nonces of signed internal callbacks until
+-- their signature expires, so a captured request cannot be replayed
+-- against any backend replica.
+CREATE TABLE IF NOT EXISTS request_nonces (
+    nonce TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- idempotency_keys holds the responses to internal callbacks by
+-- Idempotency-Key, shared by every backend replica. A row that is not done
+-- reserves the key while its request runs.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key TEXT PRIMARY KEY,
+    done BOOLEAN NOT NULL DEFAULT false,
+    status INTEGER NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL DEFAULT '',
+    body BYTEA,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_nonces_expires_at ON request_nonces(expires_at);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS request_nonces;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- queue_managed marks jobs whose source message SQS redelivers when the
+-- worker dies, so the stale job reaper must not enqueue another copy.
+ALTER TABLE transcoding_jobs
+    ADD COLUMN IF NOT EXISTS queue_managed BOOLEAN NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE transcoding_jobs
+    DROP COLUMN IF EXISTS queue_managed;
+-- +goose StatementEnd
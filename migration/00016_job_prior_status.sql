@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- prior_status is the video status when the job started. A job of a video
+-- that was already published leaves the status alone, so the video keeps
+-- playing its old outputs while it is transcoded again.
+ALTER TABLE transcoding_jobs
+    ADD COLUMN IF NOT EXISTS prior_status TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+ALTER TABLE transcoding_jobs
+    DROP COLUMN IF EXISTS prior_status;
+-- +goose StatementEnd
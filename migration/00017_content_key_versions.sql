@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- Every job encrypts under new keys, so content_keys keeps one row per key
+-- ID. Keys of earlier jobs stay valid for the segments still published
+-- under them. activated_at is set when the job that registered the key
+-- reports its outputs; keys of jobs that never did are not served.
+ALTER TABLE content_keys
+    DROP CONSTRAINT IF EXISTS content_keys_pkey,
+    ADD PRIMARY KEY (video_id, scheme, key_id),
+    ADD COLUMN IF NOT EXISTS activated_at TIMESTAMP;
+
+UPDATE content_keys SET activated_at = updated_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+-- Keep the most recently activated key of each video and scheme.
+DELETE FROM content_keys
+WHERE (video_id, scheme, key_id) NOT IN (
+    SELECT DISTINCT ON (video_id, scheme) video_id, scheme, key_id
+    FROM content_keys
+    ORDER BY video_id, scheme, activated_at DESC NULLS LAST, created_at DESC
+);
+
+ALTER TABLE content_keys
+    DROP CONSTRAINT IF EXISTS content_keys_pkey,
+    DROP COLUMN IF EXISTS activated_at,
+    ADD PRIMARY KEY (video_id, scheme);
+-- +goose StatementEnd
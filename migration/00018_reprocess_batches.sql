@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+
+-- reprocess_batches records admin reprocess requests. Each selected video
+-- gets a reprocess_items row that stays PENDING until its upload event is
+-- sent, so a batch survives backend restarts and its progress can be read.
+CREATE TABLE IF NOT EXISTS reprocess_batches (
+    id UUID PRIMARY KEY,
+    packaging TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- status is PENDING, ENQUEUED, SKIPPED or FAILED; error says why an item
+-- was not enqueued.
+CREATE TABLE IF NOT EXISTS reprocess_items (
+    batch_id UUID NOT NULL REFERENCES reprocess_batches(id) ON DELETE CASCADE,
+    video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'PENDING',
+    error TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (batch_id, video_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reprocess_items_pending ON reprocess_items(batch_id) WHERE status = 'PENDING';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+
+DROP TABLE IF EXISTS reprocess_items;
+DROP TABLE IF EXISTS reprocess_batches;
+-- +goose StatementEnd
@@ -1,11 +1,7 @@
 package config
 
 import (
-	"encoding/json"
-	"strings"
-
 	"gitlab.com/subrotokumar/playstack/libs/core"
-	"gitlab.com/subrotokumar/playstack/libs/storage"
 )
 
 type Config struct {
@@ -22,33 +18,70 @@ type Config struct {
 		SecretAccessKey string `yaml:"secret_key" envconfig:"AWS_SECRET_ACCESS_KEY"`
 		MediaBucket     string `yaml:"media_bucket" envconfig:"MEDIA_BUCKET" required:"true"`
 	} `yaml:"aws"`
+	// NotifierService signs and sends job callbacks to the API.
 	NotifierService struct {
-		URL      string `yaml:"api" envconfig:"NOTIFIER_SERVICE_ENDPOINT" default:"http://localhost:8080"`
-		Username string `yaml:"username" envconfig:"BASIC_AUTH_USERNAME"`
-		PASSWORD string `yaml:"password" envconfig:"BASIC_AUTH_PASSWORD"`
+		URL                  string `yaml:"api" envconfig:"NOTIFIER_SERVICE_ENDPOINT" default:"http://localhost:8080"`
+		HMACKeyID            string `yaml:"hmac_key_id" envconfig:"NOTIFIER_HMAC_KEY_ID" required:"true"`
+		HMACSecret           string `yaml:"hmac_secret" envconfig:"NOTIFIER_HMAC_SECRET" required:"true"`
+		TimeoutSec           int    `yaml:"timeout_sec" envconfig:"NOTIFIER_TIMEOUT_SEC" default:"10"`
+		MaxAttempts          int    `yaml:"max_attempts" envconfig:"NOTIFIER_MAX_ATTEMPTS" default:"5"`
+		HeartbeatIntervalSec int    `yaml:"heartbeat_interval_sec" envconfig:"NOTIFIER_HEARTBEAT_INTERVAL_SEC" default:"60"`
+		OutboxDir            string `yaml:"outbox_dir" envconfig:"NOTIFIER_OUTBOX_DIR" default:"./tmp/outbox"`
 	} `yaml:"notifier_service"`
-	Event   string `yaml:"events" envconfig:"SQS_MESSAGE" required:"true"`
-	S3Event storage.S3Event
-}
-
-func (cfg *Config) ParseS3Event() (event storage.S3Event, err error) {
-	err = json.Unmarshal([]byte(cfg.Event), &event)
-	return event, err
-}
-
-func (cfg *Config) Bucket() string {
-	return cfg.S3Event.Records[0].S3.Bucket.Name
-}
-
-func (cfg *Config) Key() string {
-	return cfg.S3Event.Records[0].S3.Object.Key
-}
-
-func (cfg *Config) UserAndVideoID() (string, string) {
-	keys := strings.Split(cfg.Key(), "/")
-	return keys[1], keys[2]
-}
-
-func (cfg *Config) ObjectSize() int64 {
-	return cfg.S3Event.Records[0].S3.Object.Size
+	Queue struct {
+		URL                  string `yaml:"url" envconfig:"SQS_QUEUE_URL"`
+		WaitTimeSec          int32  `yaml:"wait_time_sec" envconfig:"SQS_WAIT_TIME_SEC" default:"20"`
+		VisibilityTimeoutSec int32  `yaml:"visibility_timeout_sec" envconfig:"SQS_VISIBILITY_TIMEOUT_SEC" default:"300"`
+	} `yaml:"queue"`
+	Transcode struct {
+		ProfilesFile        string  `yaml:"profiles_file" envconfig:"TRANSCODE_PROFILES_FILE"`
+		Packaging           string  `yaml:"packaging" envconfig:"TRANSCODE_PACKAGING" default:"DASH,HLS"`
+		ProgressIntervalSec int     `yaml:"progress_interval_sec" envconfig:"TRANSCODE_PROGRESS_INTERVAL_SEC" default:"5"`
+		TimeoutFactor       float64 `yaml:"timeout_factor" envconfig:"TRANSCODE_TIMEOUT_FACTOR" default:"5"`
+		MinTimeoutSec       int     `yaml:"min_timeout_sec" envconfig:"TRANSCODE_MIN_TIMEOUT_SEC" default:"900"`
+		ThumbnailCount      int     `yaml:"thumbnail_count" envconfig:"TRANSCODE_THUMBNAIL_COUNT" default:"5"`
+		SpriteIntervalSec   int     `yaml:"sprite_interval_sec" envconfig:"TRANSCODE_SPRITE_INTERVAL_SEC" default:"10"`
+		SpriteColumns       int     `yaml:"sprite_columns" envconfig:"TRANSCODE_SPRITE_COLUMNS" default:"5"`
+		SpriteRows          int     `yaml:"sprite_rows" envconfig:"TRANSCODE_SPRITE_ROWS" default:"5"`
+		SpriteWidth         int     `yaml:"sprite_width" envconfig:"TRANSCODE_SPRITE_WIDTH" default:"160"`
+	} `yaml:"transcode"`
+	// PerTitle sizes each rung from sample encodes of the source.
+	PerTitle struct {
+		Enabled          bool    `yaml:"enabled" envconfig:"PER_TITLE_ENABLED" default:"false"`
+		Samples          int     `yaml:"samples" envconfig:"PER_TITLE_SAMPLES" default:"3"`
+		SampleSec        int     `yaml:"sample_sec" envconfig:"PER_TITLE_SAMPLE_SEC" default:"8"`
+		CRFs             []int   `yaml:"crfs" envconfig:"PER_TITLE_CRFS" default:"20,23,26,29,32"`
+		MinPSNR          float64 `yaml:"min_psnr" envconfig:"PER_TITLE_MIN_PSNR" default:"38"`
+		MinSSIM          float64 `yaml:"min_ssim" envconfig:"PER_TITLE_MIN_SSIM" default:"0.96"`
+		MinBitrateFactor float64 `yaml:"min_bitrate_factor" envconfig:"PER_TITLE_MIN_BITRATE_FACTOR" default:"0.3"`
+		MaxBitrateFactor float64 `yaml:"max_bitrate_factor" envconfig:"PER_TITLE_MAX_BITRATE_FACTOR" default:"1.5"`
+	} `yaml:"per_title"`
+	// Encryption encrypts published segments under per-video keys.
+	Encryption struct {
+		HLS        bool   `yaml:"hls" envconfig:"ENCRYPTION_HLS" default:"false"`
+		DASH       bool   `yaml:"dash" envconfig:"ENCRYPTION_DASH" default:"false"`
+		KeyBaseURL string `yaml:"key_base_url" envconfig:"ENCRYPTION_KEY_BASE_URL"`
+	} `yaml:"encryption"`
+	// Validation rejects sources before they are transcoded.
+	Validation struct {
+		VideoCodecs    []string `yaml:"video_codecs" envconfig:"VALIDATION_VIDEO_CODECS" default:"h264,hevc,vp8,vp9,av1,mpeg4,mpeg2video,prores"`
+		MaxDurationSec int      `yaml:"max_duration_sec" envconfig:"VALIDATION_MAX_DURATION_SEC" default:"14400"`
+		MaxWidth       int      `yaml:"max_width" envconfig:"VALIDATION_MAX_WIDTH" default:"3840"`
+		MaxHeight      int      `yaml:"max_height" envconfig:"VALIDATION_MAX_HEIGHT" default:"2160"`
+	} `yaml:"validation"`
+	// Transfer tunes S3 downloads and uploads.
+	Transfer struct {
+		Concurrency          int   `yaml:"concurrency" envconfig:"TRANSFER_CONCURRENCY" default:"8"`
+		MultipartThresholdMB int64 `yaml:"multipart_threshold_mb" envconfig:"TRANSFER_MULTIPART_THRESHOLD_MB" default:"64"`
+		PartSizeMB           int64 `yaml:"part_size_mb" envconfig:"TRANSFER_PART_SIZE_MB" default:"16"`
+		MaxAttempts          int   `yaml:"max_attempts" envconfig:"TRANSFER_MAX_ATTEMPTS" default:"4"`
+		VerifyChecksum       bool  `yaml:"verify_checksum" envconfig:"TRANSFER_VERIFY_CHECKSUM" default:"true"`
+	} `yaml:"transfer"`
+	// Workspace holds one scratch directory per job attempt.
+	Workspace struct {
+		Root      string `yaml:"root" envconfig:"SCRATCH_DIR" default:"./tmp/workspace"`
+		MaxSizeMB int64  `yaml:"max_size_mb" envconfig:"SCRATCH_MAX_SIZE_MB" default:"0"`
+	} `yaml:"workspace"`
+	// Event is a single S3 event for one-shot runs instead of Queue.URL.
+	Event string `yaml:"events" envconfig:"SQS_MESSAGE"`
 }
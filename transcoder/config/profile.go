@@ -1,13 +1,98 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gitlab.com/subrotokumar/playstack/libs/core"
+)
+
 type QualityProfile struct {
-	Name         string
-	Resolution   string
-	VideoBitrate string
-	AudioBitrate string
-	VideoCodec   string
-	AudioCodec   string
-	Preset       string
+	Name         string `yaml:"name"`
+	Resolution   string `yaml:"resolution"`
+	VideoBitrate string `yaml:"video_bitrate"`
+	AudioBitrate string `yaml:"audio_bitrate"`
+	VideoCodec   string `yaml:"video_codec"`
+	AudioCodec   string `yaml:"audio_codec"`
+	Preset       string `yaml:"preset"`
+	// Scale selects how the source is fitted to Resolution; see ScaleMode.
+	Scale ScaleMode `yaml:"scale"`
+	// Pad letterboxes fitted output to the exact Resolution. It only
+	// applies to ScaleFit.
+	Pad bool `yaml:"pad"`
+	// Codecs encodes the rung again with other video encoders, e.g. HEVC
+	// or AV1, next to the VideoCodec rendition that stays the fallback.
+	Codecs []CodecVariant `yaml:"codecs"`
+}
+
+// CodecVariant is an additional encoding of a rung. Audio, resolution and
+// scaling are taken from the rung.
+type CodecVariant struct {
+	VideoCodec string `yaml:"video_codec"`
+	// VideoBitrate defaults to the rung bitrate scaled by the codec's
+	// BitrateFactor.
+	VideoBitrate string `yaml:"video_bitrate"`
+	// Preset is passed to the encoder as is: a name for libx265, 0-13 for
+	// libsvtav1 and the cpu-used level, 0-8, for libaom-av1. The encoder
+	// default is used when it is empty.
+	Preset string `yaml:"preset"`
+}
+
+// ScaleMode controls how a rendition keeps the source aspect ratio.
+// Resolution is always given landscape and is flipped for portrait sources.
+type ScaleMode string
+
+const (
+	// ScaleFit fits the source inside Resolution. It is the default.
+	ScaleFit ScaleMode = "fit"
+	// ScaleHeight fixes the height and derives the width.
+	ScaleHeight ScaleMode = "height"
+	// ScaleWidth fixes the width and derives the height.
+	ScaleWidth ScaleMode = "width"
+)
+
+// Video codec families, as reported to the API.
+const (
+	CodecH264 = "h264"
+	CodecHEVC = "hevc"
+	CodecAV1  = "av1"
+)
+
+// encoderFamilies maps the supported video encoders to their codec family.
+// All of them run on the CPU.
+var encoderFamilies = map[string]string{
+	"libx264":    CodecH264,
+	"libx265":    CodecHEVC,
+	"libsvtav1":  CodecAV1,
+	"libaom-av1": CodecAV1,
+}
+
+// BitrateFactor is the share of the H.264 bitrate a codec family needs
+// for similar quality. It sets the default bitrate of a CodecVariant.
+var BitrateFactor = map[string]float64{
+	CodecH264: 1,
+	CodecHEVC: 0.6,
+	CodecAV1:  0.5,
+}
+
+// CodecFamily returns the codec family of a video encoder, or "" if the
+// encoder is not supported.
+func CodecFamily(encoder string) string {
+	return encoderFamilies[encoder]
+}
+
+// Ladder is the layout of a profiles YAML file:
+//
+//	profiles:
+//	  - name: 720p
+//	    resolution: 1280x720
+//	    video_bitrate: 3000k
+//	    ...
+type Ladder struct {
+	Profiles []QualityProfile `yaml:"profiles"`
 }
 
 var Profiles = []QualityProfile{
@@ -48,3 +133,143 @@ var Profiles = []QualityProfile{
 		Preset:       "fast",
 	},
 }
+
+// LoadProfiles reads a quality ladder from a YAML file. An empty path
+// returns the built-in Profiles.
+func LoadProfiles(path string) ([]QualityProfile, error) {
+	if path == "" {
+		return Profiles, nil
+	}
+
+	var ladder Ladder
+	if err := core.ConfigFromFile(&ladder, path); err != nil {
+		return nil, err
+	}
+	if len(ladder.Profiles) == 0 {
+		return nil, errors.New("profiles file has no profiles")
+	}
+	for i, p := range ladder.Profiles {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("profile %d: %w", i, err)
+		}
+	}
+	return ladder.Profiles, nil
+}
+
+func (p QualityProfile) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if _, _, err := p.Size(); err != nil {
+		return err
+	}
+	if _, err := ParseBitrate(p.VideoBitrate); err != nil {
+		return fmt.Errorf("video_bitrate: %w", err)
+	}
+	if _, err := ParseBitrate(p.AudioBitrate); err != nil {
+		return fmt.Errorf("audio_bitrate: %w", err)
+	}
+	if p.VideoCodec == "" || p.AudioCodec == "" {
+		return errors.New("video_codec and audio_codec are required")
+	}
+	families := []string{CodecFamily(p.VideoCodec)}
+	for i, v := range p.Codecs {
+		family := CodecFamily(v.VideoCodec)
+		if family == "" {
+			return fmt.Errorf("codecs %d: unsupported video_codec %q", i, v.VideoCodec)
+		}
+		if slices.Contains(families, family) {
+			return fmt.Errorf("codecs %d: %s is encoded twice", i, family)
+		}
+		families = append(families, family)
+		if v.VideoBitrate != "" {
+			if _, err := ParseBitrate(v.VideoBitrate); err != nil {
+				return fmt.Errorf("codecs %d: video_bitrate: %w", i, err)
+			}
+		}
+	}
+	switch p.ScaleMode() {
+	case ScaleFit, ScaleHeight, ScaleWidth:
+	default:
+		return fmt.Errorf("invalid scale %q", p.Scale)
+	}
+	if p.Pad && p.ScaleMode() != ScaleFit {
+		return errors.New("pad requires fit scaling")
+	}
+	return nil
+}
+
+// Variants returns the rung in each of its Codecs, as profiles of their
+// own without Codecs. Bitrates left empty are derived from the rung.
+func (p QualityProfile) Variants() []QualityProfile {
+	variants := make([]QualityProfile, 0, len(p.Codecs))
+	for _, v := range p.Codecs {
+		variant := p
+		variant.Codecs = nil
+		variant.VideoCodec = v.VideoCodec
+		variant.Preset = v.Preset
+		variant.VideoBitrate = v.VideoBitrate
+		if variant.VideoBitrate == "" {
+			factor := BitrateFactor[CodecFamily(v.VideoCodec)]
+			variant.VideoBitrate = strconv.Itoa(max(int(float64(p.VideoBitrateKbps())*factor), 1)) + "k"
+		}
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+// ScaleMode returns Scale, defaulting to ScaleFit.
+func (p QualityProfile) ScaleMode() ScaleMode {
+	if p.Scale == "" {
+		return ScaleFit
+	}
+	return p.Scale
+}
+
+// Size returns the width and height from a WIDTHxHEIGHT resolution.
+func (p QualityProfile) Size() (int, int, error) {
+	w, h, ok := strings.Cut(p.Resolution, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid resolution %q", p.Resolution)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid resolution %q", p.Resolution)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid resolution %q", p.Resolution)
+	}
+	return width, height, nil
+}
+
+// VideoBitrateKbps returns the video bitrate in kbit/s, or 0 if it is invalid.
+func (p QualityProfile) VideoBitrateKbps() int {
+	kbps, _ := ParseBitrate(p.VideoBitrate)
+	return kbps
+}
+
+// AudioBitrateKbps returns the audio bitrate in kbit/s, or 0 if it is invalid.
+func (p QualityProfile) AudioBitrateKbps() int {
+	kbps, _ := ParseBitrate(p.AudioBitrate)
+	return kbps
+}
+
+// ParseBitrate converts an ffmpeg style bitrate ("800k", "5M", "96000") to kbit/s.
+func ParseBitrate(bitrate string) (int, error) {
+	value := strings.ToLower(strings.TrimSpace(bitrate))
+	multiplier := 0.001
+	switch {
+	case strings.HasSuffix(value, "k"):
+		multiplier = 1
+		value = strings.TrimSuffix(value, "k")
+	case strings.HasSuffix(value, "m"):
+		multiplier = 1000
+		value = strings.TrimSuffix(value, "m")
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q", bitrate)
+	}
+	return int(n * multiplier), nil
+}
@@ -1,18 +1,22 @@
 package ffmpeg
 
 import (
+	"context"
 	"encoding/json"
-	"os/exec"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 )
 
-func AnalyzeVideo(inputPath string) (*VideoInfo, error) {
-	cmd := exec.Command("ffprobe",
+func AnalyzeVideo(ctx context.Context, inputPath string) (*VideoInfo, error) {
+	cmd := Command(ctx, []string{"ffprobe",
 		"-v", "quiet",
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
 		inputPath,
-	)
+	})
 
 	output, err := cmd.Output()
 	if err != nil {
@@ -20,21 +24,152 @@ func AnalyzeVideo(inputPath string) (*VideoInfo, error) {
 	}
 
 	var info VideoInfo
-	json.Unmarshal(output, &info)
-
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("parse ffprobe output: %w", err)
+	}
 	return &info, nil
 }
 
 type VideoInfo struct {
 	Format struct {
-		Duration string `json:"duration"`
-		Size     string `json:"size"`
-		BitRate  string `json:"bit_rate"`
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+		BitRate    string `json:"bit_rate"`
 	} `json:"format"`
-	Streams []struct {
-		CodecType string `json:"codec_type"`
-		Width     int    `json:"width"`
-		Height    int    `json:"height"`
-		FrameRate string `json:"r_frame_rate"`
-	} `json:"streams"`
+	Streams []Stream `json:"streams"`
+}
+
+type Stream struct {
+	CodecType    string            `json:"codec_type"`
+	CodecName    string            `json:"codec_name"`
+	Channels     int               `json:"channels"`
+	Width        int               `json:"width"`
+	Height       int               `json:"height"`
+	FrameRate    string            `json:"r_frame_rate"`
+	BitRate      string            `json:"bit_rate"`
+	Tags         map[string]string `json:"tags"`
+	SideDataList []struct {
+		SideDataType string  `json:"side_data_type"`
+		Rotation     float64 `json:"rotation"`
+	} `json:"side_data_list"`
+}
+
+// Metadata is the summary of a probe stored with the video.
+type Metadata struct {
+	Container     string  `json:"container"`
+	VideoCodec    string  `json:"video_codec,omitempty"`
+	Width         int     `json:"width,omitempty"`
+	Height        int     `json:"height,omitempty"`
+	FPS           float64 `json:"fps,omitempty"`
+	BitrateKbps   int     `json:"bitrate_kbps,omitempty"`
+	AudioCodec    string  `json:"audio_codec,omitempty"`
+	AudioChannels int     `json:"audio_channels,omitempty"`
+}
+
+// VideoStream returns the first video stream, or nil if there is none.
+func (v *VideoInfo) VideoStream() *Stream {
+	return v.stream("video")
+}
+
+// AudioStream returns the first audio stream, or nil if there is none.
+func (v *VideoInfo) AudioStream() *Stream {
+	return v.stream("audio")
+}
+
+func (v *VideoInfo) stream(codecType string) *Stream {
+	for i := range v.Streams {
+		if v.Streams[i].CodecType == codecType {
+			return &v.Streams[i]
+		}
+	}
+	return nil
+}
+
+// Metadata summarizes the probe. Width and height are the display size,
+// after rotation.
+func (v *VideoInfo) Metadata() Metadata {
+	m := Metadata{
+		Container:   v.Format.FormatName,
+		BitrateKbps: v.BitRateKbps(),
+	}
+	if stream := v.VideoStream(); stream != nil {
+		m.VideoCodec = stream.CodecName
+		m.Width, m.Height = stream.DisplaySize()
+		m.FPS = math.Round(stream.FPS()*1000) / 1000
+	}
+	if stream := v.AudioStream(); stream != nil {
+		m.AudioCodec = stream.CodecName
+		m.AudioChannels = stream.Channels
+	}
+	return m
+}
+
+// DurationSec returns the container duration in seconds, or 0 if unknown.
+func (v *VideoInfo) DurationSec() float64 {
+	d, _ := strconv.ParseFloat(v.Format.Duration, 64)
+	return d
+}
+
+// BitRateKbps returns the video stream bitrate, falling back to the overall
+// container bitrate when the stream does not report one. It is 0 if unknown.
+func (v *VideoInfo) BitRateKbps() int {
+	if stream := v.VideoStream(); stream != nil {
+		if bps, err := strconv.Atoi(stream.BitRate); err == nil && bps > 0 {
+			return bps / 1000
+		}
+	}
+	bps, _ := strconv.Atoi(v.Format.BitRate)
+	return bps / 1000
+}
+
+// FPS parses the rational frame rate reported by ffprobe, e.g. "30000/1001".
+func (s *Stream) FPS() float64 {
+	return parseRate(s.FrameRate)
+}
+
+// parseRate parses a frame rate written as "30" or "30000/1001". It returns
+// 0 when the rate is malformed.
+func parseRate(value string) float64 {
+	num, den, ok := strings.Cut(value, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !ok {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// Rotation returns the clockwise display rotation in degrees (0, 90, 180 or
+// 270). Phones store it either as a display matrix or a legacy rotate tag.
+func (s *Stream) Rotation() int {
+	var degrees float64
+	if rotate, ok := s.Tags["rotate"]; ok {
+		degrees, _ = strconv.ParseFloat(rotate, 64)
+	}
+	for _, sd := range s.SideDataList {
+		if sd.SideDataType == "Display Matrix" {
+			// The display matrix rotation is counter-clockwise.
+			degrees = -sd.Rotation
+		}
+	}
+	normalized := int(math.Round(degrees/90)) * 90 % 360
+	if normalized < 0 {
+		normalized += 360
+	}
+	return normalized
+}
+
+// DisplaySize returns the frame size after rotation is applied.
+func (s *Stream) DisplaySize() (int, int) {
+	if s.Rotation()%180 != 0 {
+		return s.Height, s.Width
+	}
+	return s.Width, s.Height
 }
@@ -0,0 +1,150 @@
+package ffmpeg
+
+import (
+	"encoding/hex"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/config"
+)
+
+// ClearKeySystemID is the DASH-IF system ID of W3C ClearKey.
+const ClearKeySystemID = "urn:uuid:e2719d58-a985-b3c9-781a-b030af78d30e"
+
+var (
+	initSegmentPattern = regexp.MustCompile(`^init-stream(\d+)\.m4s$`)
+	adaptationSetTag   = regexp.MustCompile(`<AdaptationSet[^>]*?>`)
+	mpdTag             = regexp.MustCompile(`<MPD[^>]*?>`)
+)
+
+// JoinSegments concatenates the init segment and the media segments of each
+// stream in srcDir into dstDir/stream<n>.mp4, a fragmented MP4 ffmpeg can
+// read back. The paths are returned in stream order.
+func JoinSegments(srcDir, dstDir string) ([]string, error) {
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	var streams []int
+	for _, entry := range entries {
+		if m := initSegmentPattern.FindStringSubmatch(entry.Name()); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			streams = append(streams, n)
+		}
+	}
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("no init segments in %s", srcDir)
+	}
+	sort.Ints(streams)
+	if err := os.MkdirAll(dstDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(streams))
+	for _, n := range streams {
+		chunks, err := filepath.Glob(filepath.Join(srcDir, fmt.Sprintf("chunk-stream%d-*.m4s", n)))
+		if err != nil {
+			return nil, err
+		}
+		// Segment numbers are zero-padded, so names sort in playback order.
+		sort.Strings(chunks)
+		path := filepath.Join(dstDir, fmt.Sprintf("stream%d.mp4", n))
+		if err := concatFiles(path, append([]string{filepath.Join(srcDir, fmt.Sprintf("init-stream%d.m4s", n))}, chunks...)); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// CencCommand repackages the streams joined by JoinSegments into a DASH
+// output whose segments are encrypted with CENC (cenc-aes-ctr) under key
+// and kid. Streams are copied, not encoded again, so segment boundaries
+// and adaptation sets match the clear output of profiles.
+func CencCommand(streamPaths []string, outputDir string, profiles []config.QualityProfile, key, kid []byte) []string {
+	args := []string{"ffmpeg"}
+	for _, path := range streamPaths {
+		args = append(args, "-i", path)
+	}
+	for i := range streamPaths {
+		args = append(args, "-map", strconv.Itoa(i))
+	}
+	return append(args,
+		"-c", "copy",
+		"-use_timeline", "1",
+		"-use_template", "1",
+		"-window_size", "0",
+		"-seg_duration", strconv.Itoa(segmentDuration),
+		"-adaptation_sets", adaptationSets(profiles),
+		"-dash_segment_type", "mp4",
+		"-format_options", fmt.Sprintf("encryption_scheme=cenc-aes-ctr:encryption_key=%s:encryption_kid=%s",
+			hex.EncodeToString(key), hex.EncodeToString(kid)),
+
+		"-f", "dash",
+		outputDir+"/"+DashManifest,
+	)
+}
+
+// AddContentProtection adds the ContentProtection elements of a CENC
+// output to every adaptation set of the manifest at path: the mp4protection
+// descriptor with the default KID, and a ClearKey descriptor that points
+// players to licenseURL, escaped for XML. ffmpeg writes neither.
+func AddContentProtection(path, kid, licenseURL string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	manifest := string(data)
+	root := mpdTag.FindString(manifest)
+	if root == "" {
+		return fmt.Errorf("%s has no MPD element", filepath.Base(path))
+	}
+	namespaces := ""
+	if !strings.Contains(root, `xmlns:cenc=`) {
+		namespaces += ` xmlns:cenc="urn:mpeg:cenc:2013"`
+	}
+	if !strings.Contains(root, `xmlns:dashif=`) {
+		namespaces += ` xmlns:dashif="https://dashif.org/CPS"`
+	}
+	manifest = strings.Replace(manifest, root, strings.TrimSuffix(root, ">")+namespaces+">", 1)
+
+	protection := fmt.Sprintf(`
+			<ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc" cenc:default_KID="%s"/>
+			<ContentProtection schemeIdUri="%s" value="ClearKey1.0">
+				<dashif:Laurl>%s</dashif:Laurl>
+			</ContentProtection>`, kid, ClearKeySystemID, html.EscapeString(licenseURL))
+	count := 0
+	manifest = adaptationSetTag.ReplaceAllStringFunc(manifest, func(tag string) string {
+		count++
+		return tag + protection
+	})
+	if count == 0 {
+		return fmt.Errorf("%s has no adaptation sets", filepath.Base(path))
+	}
+	return os.WriteFile(path, []byte(manifest), 0o644)
+}
+
+func concatFiles(dst string, srcs []string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		if _, err := out.Write(data); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
@@ -0,0 +1,135 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	representationTag = regexp.MustCompile(`<Representation\s[^>]*>`)
+	idAttr            = regexp.MustCompile(`\sid="([^"]*)"`)
+	codecsAttr        = regexp.MustCompile(`\scodecs="([^"]*)"`)
+)
+
+// FixCodecs completes the codecs attribute of representations in the
+// DashManifest of dir that ffmpeg leaves as a bare sample entry type, as
+// it does for HEVC. The RFC 6381 string is built from the configuration
+// box in the representation's init segment, so players and the HLS master
+// can tell which variants a device decodes.
+func FixCodecs(dir string) error {
+	path := filepath.Join(dir, DashManifest)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fixErr error
+	manifest := representationTag.ReplaceAllStringFunc(string(data), func(tag string) string {
+		codecs := codecsAttr.FindStringSubmatch(tag)
+		id := idAttr.FindStringSubmatch(tag)
+		if codecs == nil || id == nil || strings.Contains(codecs[1], ".") {
+			return tag
+		}
+		init, err := os.ReadFile(filepath.Join(dir, "init-stream"+id[1]+".m4s"))
+		if err != nil {
+			fixErr = err
+			return tag
+		}
+		codec, err := codecString(codecs[1], init)
+		if err != nil {
+			fixErr = fmt.Errorf("representation %s: %w", id[1], err)
+			return tag
+		}
+		return strings.Replace(tag, codecs[0], ` codecs="`+codec+`"`, 1)
+	})
+	if fixErr != nil {
+		return fixErr
+	}
+	return os.WriteFile(path, []byte(manifest), 0o644)
+}
+
+// codecString returns the RFC 6381 codecs value of a sample entry type
+// from the init segment, or the type itself when it needs no parameters.
+func codecString(entry string, init []byte) (string, error) {
+	switch entry {
+	case "hvc1", "hev1":
+		config, err := findBox(init, "hvcC", 13)
+		if err != nil {
+			return "", err
+		}
+		return hevcCodecString(entry, config), nil
+	case "av01":
+		config, err := findBox(init, "av1C", 3)
+		if err != nil {
+			return "", err
+		}
+		return av1CodecString(config), nil
+	}
+	return entry, nil
+}
+
+// hevcCodecString formats an HEVCDecoderConfigurationRecord as described in
+// ISO/IEC 14496-15 Annex E, e.g. "hvc1.1.6.L93.B0".
+func hevcCodecString(entry string, config []byte) string {
+	profileSpace := config[1] >> 6
+	tier := "L"
+	if config[1]&0x20 != 0 {
+		tier = "H"
+	}
+	profile := config[1] & 0x1f
+	compatibility := bits.Reverse32(binary.BigEndian.Uint32(config[2:6]))
+	level := config[12]
+
+	var b strings.Builder
+	b.WriteString(entry + ".")
+	if profileSpace > 0 {
+		b.WriteByte('A' + profileSpace - 1)
+	}
+	fmt.Fprintf(&b, "%d.%X.%s%d", profile, compatibility, tier, level)
+	constraints := bytes.TrimRight(config[6:12], "\x00")
+	for _, c := range constraints {
+		fmt.Fprintf(&b, ".%X", c)
+	}
+	return b.String()
+}
+
+// av1CodecString formats an AV1CodecConfigurationRecord in the short form
+// of the AV1 ISO-BMFF binding, e.g. "av01.0.08M.08".
+func av1CodecString(config []byte) string {
+	profile := config[1] >> 5
+	level := config[1] & 0x1f
+	tier := "M"
+	if config[2]&0x80 != 0 {
+		tier = "H"
+	}
+	depth := 8
+	if config[2]&0x40 != 0 {
+		depth = 10
+		if profile == 2 && config[2]&0x20 != 0 {
+			depth = 12
+		}
+	}
+	return fmt.Sprintf("av01.%d.%02d%s.%02d", profile, level, tier, depth)
+}
+
+// findBox returns the payload of the first box of type name in data, which
+// must be at least minSize bytes. Sample entries nest their configuration
+// box behind fixed fields, so the box is located by its type rather than
+// by walking the box tree.
+func findBox(data []byte, name string, minSize int) ([]byte, error) {
+	i := bytes.Index(data, []byte(name))
+	if i < 4 {
+		return nil, fmt.Errorf("no %s box in init segment", name)
+	}
+	size := int(binary.BigEndian.Uint32(data[i-4 : i]))
+	end := i - 4 + size
+	if size < 8+minSize || end > len(data) {
+		return nil, fmt.Errorf("truncated %s box", name)
+	}
+	return data[i+4 : end], nil
+}
@@ -0,0 +1,21 @@
+package ffmpeg
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// killGracePeriod bounds how long Wait keeps reading output after a
+// cancelled command has been killed.
+const killGracePeriod = 5 * time.Second
+
+// Command builds an ffmpeg/ffprobe command bound to ctx. The process runs
+// in its own process group where supported, and cancelling ctx kills the
+// whole group so no child encoder outlives the job.
+func Command(ctx context.Context, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.WaitDelay = killGracePeriod
+	setProcessGroup(cmd)
+	return cmd
+}
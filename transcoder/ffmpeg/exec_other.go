@@ -0,0 +1,9 @@
+//go:build !unix
+
+package ffmpeg
+
+import "os/exec"
+
+// setProcessGroup keeps the exec.CommandContext default of killing only the
+// direct child on platforms without process groups.
+func setProcessGroup(*exec.Cmd) {}
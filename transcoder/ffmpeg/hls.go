@@ -0,0 +1,125 @@
+package ffmpeg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hlsAudioGroup is the EXT-X-MEDIA group every variant plays audio from.
+const hlsAudioGroup = "audio"
+
+// mpd is the part of a DASH manifest needed to describe its streams.
+type mpd struct {
+	Periods []struct {
+		AdaptationSets []struct {
+			ContentType     string              `xml:"contentType,attr"`
+			MimeType        string              `xml:"mimeType,attr"`
+			FrameRate       string              `xml:"frameRate,attr"`
+			Representations []mpdRepresentation `xml:"Representation"`
+		} `xml:"AdaptationSet"`
+	} `xml:"Period"`
+}
+
+type mpdRepresentation struct {
+	ID        string `xml:"id,attr"`
+	MimeType  string `xml:"mimeType,attr"`
+	Codecs    string `xml:"codecs,attr"`
+	Bandwidth int    `xml:"bandwidth,attr"`
+	Width     int    `xml:"width,attr"`
+	Height    int    `xml:"height,attr"`
+	FrameRate string `xml:"frameRate,attr"`
+	// kind is "video" or "audio", taken from the adaptation set.
+	kind string
+}
+
+// WriteHLSMaster replaces the master playlist in outputDir with one built
+// from DashManifest, so every variant carries the CODECS, RESOLUTION and
+// BANDWIDTH of the renditions ffmpeg actually encoded. Media playlists are
+// the media_<id>.m3u8 files written by CmafCommand.
+func WriteHLSMaster(outputDir string) error {
+	data, err := os.ReadFile(filepath.Join(outputDir, DashManifest))
+	if err != nil {
+		return err
+	}
+	var manifest mpd
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("parse %s: %w", DashManifest, err)
+	}
+
+	var videos, audios []mpdRepresentation
+	for _, period := range manifest.Periods {
+		for _, set := range period.AdaptationSets {
+			for _, rep := range set.Representations {
+				rep.kind = set.ContentType
+				rep.FrameRate = firstNonEmpty(rep.FrameRate, set.FrameRate)
+				if rep.kind == "" {
+					rep.kind, _, _ = strings.Cut(firstNonEmpty(rep.MimeType, set.MimeType), "/")
+				}
+				switch rep.kind {
+				case "video":
+					videos = append(videos, rep)
+				case "audio":
+					audios = append(audios, rep)
+				}
+			}
+		}
+	}
+	if len(videos) == 0 {
+		return fmt.Errorf("%s has no video representations", DashManifest)
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-INDEPENDENT-SEGMENTS\n\n")
+
+	var audioBandwidth int
+	var audioCodecs string
+	for i, rep := range audios {
+		def := "NO"
+		if i == 0 {
+			def, audioCodecs = "YES", rep.Codecs
+		}
+		audioBandwidth = max(audioBandwidth, rep.Bandwidth)
+		fmt.Fprintf(&b, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=%q,NAME=\"audio_%dk\",DEFAULT=%s,AUTOSELECT=YES,URI=%q\n",
+			hlsAudioGroup, rep.Bandwidth/1000, def, mediaPlaylist(rep.ID))
+	}
+	if len(audios) > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, rep := range videos {
+		codecs := rep.Codecs
+		if audioCodecs != "" {
+			codecs += "," + audioCodecs
+		}
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=%q", rep.Bandwidth+audioBandwidth, codecs)
+		if rep.Width > 0 && rep.Height > 0 {
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", rep.Width, rep.Height)
+		}
+		if fps := parseRate(rep.FrameRate); fps > 0 {
+			fmt.Fprintf(&b, ",FRAME-RATE=%.3f", fps)
+		}
+		if len(audios) > 0 {
+			fmt.Fprintf(&b, ",AUDIO=%q", hlsAudioGroup)
+		}
+		fmt.Fprintf(&b, "\n%s\n", mediaPlaylist(rep.ID))
+	}
+
+	return os.WriteFile(filepath.Join(outputDir, HLSMaster), []byte(b.String()), 0o644)
+}
+
+// mediaPlaylist is the name ffmpeg gives the HLS playlist of a representation.
+func mediaPlaylist(id string) string {
+	return "media_" + id + ".m3u8"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
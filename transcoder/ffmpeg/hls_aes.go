@@ -0,0 +1,136 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EncryptHLS writes an AES-128 encrypted copy of the HLS output in srcDir
+// to dstDir. Every media segment is encrypted whole with AES-128-CBC and
+// PKCS7 padding, using its media sequence number as the IV as RFC 8216
+// specifies when EXT-X-KEY has no IV attribute. Init segments stay clear,
+// since EXT-X-KEY is placed after EXT-X-MAP, and the media playlists point
+// players to keyURI for the key.
+func EncryptHLS(srcDir, dstDir string, key []byte, keyURI string) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dstDir, 0o755); err != nil {
+		return err
+	}
+	master, err := os.ReadFile(filepath.Join(srcDir, HLSMaster))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dstDir, HLSMaster), master, 0o644); err != nil {
+		return err
+	}
+
+	playlists, err := filepath.Glob(filepath.Join(srcDir, mediaPlaylist("*")))
+	if err != nil {
+		return err
+	}
+	if len(playlists) == 0 {
+		return fmt.Errorf("no media playlists in %s", srcDir)
+	}
+	for _, playlist := range playlists {
+		if err := encryptMediaPlaylist(block, srcDir, dstDir, filepath.Base(playlist), keyURI); err != nil {
+			return fmt.Errorf("encrypt %s: %w", filepath.Base(playlist), err)
+		}
+	}
+	return nil
+}
+
+// encryptMediaPlaylist encrypts the segments of one media playlist and
+// writes the playlist with an EXT-X-KEY tag before its first segment.
+func encryptMediaPlaylist(block cipher.Block, srcDir, dstDir, name, keyURI string) error {
+	data, err := os.ReadFile(filepath.Join(srcDir, name))
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	var sequence uint64
+	keyWritten := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, err = strconv.ParseUint(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+			if err != nil {
+				return fmt.Errorf("parse media sequence: %w", err)
+			}
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE"):
+			return fmt.Errorf("byte-range segments are not supported")
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			uri, ok := attribute(line, "URI")
+			if !ok {
+				return fmt.Errorf("EXT-X-MAP without URI")
+			}
+			if err := copyFile(filepath.Join(srcDir, uri), filepath.Join(dstDir, uri)); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "#EXTINF:") && !keyWritten:
+			fmt.Fprintf(&out, "#EXT-X-KEY:METHOD=AES-128,URI=%q\n", keyURI)
+			keyWritten = true
+		case line != "" && !strings.HasPrefix(line, "#"):
+			if err := encryptSegment(block, filepath.Join(srcDir, line), filepath.Join(dstDir, line), sequence); err != nil {
+				return err
+			}
+			sequence++
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dstDir, name), out.Bytes(), 0o644)
+}
+
+// encryptSegment writes src to dst encrypted with AES-128-CBC, PKCS7
+// padding and the big-endian sequence number as IV.
+func encryptSegment(block cipher.Block, src, dst string, sequence uint64) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(data, bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], sequence)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return os.WriteFile(dst, data, 0o644)
+}
+
+// attribute returns the value of name in a tag's attribute list, without
+// quotes.
+func attribute(line, name string) (string, bool) {
+	_, list, _ := strings.Cut(line, ":")
+	for _, attr := range strings.Split(list, ",") {
+		key, value, ok := strings.Cut(attr, "=")
+		if ok && key == name {
+			return strings.Trim(value, `"`), true
+		}
+	}
+	return "", false
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o644)
+}
@@ -0,0 +1,192 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/config"
+)
+
+// segmentGOPs is the number of keyframe intervals that fit in a segment.
+const segmentGOPs = 3
+
+// SelectProfiles drops every rung larger than the source and caps each
+// remaining rung's video bitrate at the source bitrate, so nothing is ever
+// upscaled or padded with wasted bits. Rungs are compared on their short
+// side, so a portrait 1080x1920 clip still gets the 1080p rung. A source
+// smaller than the lowest rung keeps that rung at the source resolution.
+func SelectProfiles(profiles []config.QualityProfile, source *VideoInfo) []config.QualityProfile {
+	stream := source.VideoStream()
+	if stream == nil || stream.Width == 0 || stream.Height == 0 {
+		return profiles
+	}
+	width, height := stream.DisplaySize()
+	sourceShort := min(width, height)
+	sourceKbps := source.BitRateKbps()
+
+	var (
+		selected    []config.QualityProfile
+		lowest      config.QualityProfile
+		lowestShort = math.MaxInt
+	)
+	for _, p := range profiles {
+		w, h, err := p.Size()
+		if err != nil {
+			continue
+		}
+		short := min(w, h)
+		if short < lowestShort {
+			lowest, lowestShort = p, short
+		}
+		if short > sourceShort {
+			continue
+		}
+		selected = append(selected, capBitrate(p, sourceKbps))
+	}
+
+	if len(selected) == 0 && lowestShort != math.MaxInt {
+		lowest.Resolution = fmt.Sprintf("%dx%d", even(max(width, height)), even(sourceShort))
+		selected = append(selected, capBitrate(lowest, sourceKbps))
+	}
+	return selected
+}
+
+// ExpandCodecs appends the codec variants of each selected rung to the
+// ladder. Rungs are grouped by codec family, in the order the families
+// first appear, so every family maps to a contiguous range of output
+// streams and its own adaptation set. The VideoCodec rungs, normally
+// H.264, come first. Variant bitrates are capped at the source bitrate
+// like the rungs themselves.
+func ExpandCodecs(profiles []config.QualityProfile, source *VideoInfo) []config.QualityProfile {
+	var families []string
+	byFamily := make(map[string][]config.QualityProfile)
+	add := func(p config.QualityProfile) {
+		family := codecGroup(p)
+		if _, ok := byFamily[family]; !ok {
+			families = append(families, family)
+		}
+		byFamily[family] = append(byFamily[family], p)
+	}
+	for _, p := range profiles {
+		primary := p
+		primary.Codecs = nil
+		add(primary)
+	}
+	sourceKbps := source.BitRateKbps()
+	for _, p := range profiles {
+		for _, variant := range p.Variants() {
+			add(capBitrate(variant, sourceKbps))
+		}
+	}
+
+	expanded := make([]config.QualityProfile, 0, len(profiles))
+	for _, family := range families {
+		expanded = append(expanded, byFamily[family]...)
+	}
+	return expanded
+}
+
+// codecGroup is the codec family of p, or its encoder name when the
+// encoder is not one of the known ones.
+func codecGroup(p config.QualityProfile) string {
+	if family := config.CodecFamily(p.VideoCodec); family != "" {
+		return family
+	}
+	return p.VideoCodec
+}
+
+// RenditionSize returns the frame size ffmpeg produces for p from source.
+func RenditionSize(p config.QualityProfile, source *VideoInfo) (int, int) {
+	boxW, boxH := profileBox(p, source)
+	if source == nil {
+		return boxW, boxH
+	}
+	stream := source.VideoStream()
+	if stream == nil || stream.Width == 0 || stream.Height == 0 {
+		return boxW, boxH
+	}
+	srcW, srcH := stream.DisplaySize()
+	aspect := float64(srcW) / float64(srcH)
+
+	switch p.ScaleMode() {
+	case config.ScaleHeight:
+		return even(int(math.Round(float64(boxH) * aspect))), boxH
+	case config.ScaleWidth:
+		return boxW, even(int(math.Round(float64(boxW) / aspect)))
+	}
+	if p.Pad {
+		return boxW, boxH
+	}
+	if float64(boxW)/float64(boxH) > aspect {
+		return even(int(float64(boxH) * aspect)), even(boxH)
+	}
+	return even(boxW), even(int(float64(boxW) / aspect))
+}
+
+// scaleFilter returns the filter chain that scales the source for p while
+// keeping its aspect ratio. Frames arrive already rotated because ffmpeg
+// applies the display matrix on decode (-autorotate is on by default).
+func scaleFilter(p config.QualityProfile, source *VideoInfo) string {
+	boxW, boxH := profileBox(p, source)
+
+	var filter string
+	switch p.ScaleMode() {
+	case config.ScaleHeight:
+		filter = fmt.Sprintf("scale=w=-2:h=%d:flags=fast_bilinear", boxH)
+	case config.ScaleWidth:
+		filter = fmt.Sprintf("scale=w=%d:h=-2:flags=fast_bilinear", boxW)
+	default:
+		filter = fmt.Sprintf(
+			"scale=w=%d:h=%d:force_original_aspect_ratio=decrease:force_divisible_by=2:flags=fast_bilinear",
+			boxW, boxH,
+		)
+		if p.Pad {
+			filter += fmt.Sprintf(",pad=w=%d:h=%d:x=(ow-iw)/2:y=(oh-ih)/2", even(boxW), even(boxH))
+		}
+	}
+	return filter + ",setsar=1"
+}
+
+// profileBox returns the profile resolution oriented like the source, so
+// portrait sources get a portrait box.
+func profileBox(p config.QualityProfile, source *VideoInfo) (int, int) {
+	w, h, _ := p.Size()
+	if source == nil {
+		return w, h
+	}
+	stream := source.VideoStream()
+	if stream == nil {
+		return w, h
+	}
+	srcW, srcH := stream.DisplaySize()
+	if (srcH > srcW) != (h > w) {
+		return h, w
+	}
+	return w, h
+}
+
+func capBitrate(p config.QualityProfile, sourceKbps int) config.QualityProfile {
+	if sourceKbps > 0 && p.VideoBitrateKbps() > sourceKbps {
+		p.VideoBitrate = strconv.Itoa(sourceKbps) + "k"
+	}
+	return p
+}
+
+// gopSize returns a keyframe interval that divides the segment duration
+// evenly at the source frame rate.
+func gopSize(source *VideoInfo) string {
+	if source != nil {
+		if stream := source.VideoStream(); stream != nil {
+			if fps := stream.FPS(); fps > 0 {
+				gop := math.Round(fps * segmentDuration / segmentGOPs)
+				return strconv.Itoa(int(math.Max(gop, 1)))
+			}
+		}
+	}
+	return defaultGOPSize
+}
+
+func even(n int) int {
+	return n - n%2
+}
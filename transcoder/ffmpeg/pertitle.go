@@ -0,0 +1,98 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/config"
+)
+
+var (
+	psnrAverage = regexp.MustCompile(`PSNR .*average:(\S+)`)
+	ssimAll     = regexp.MustCompile(`SSIM .*All:(\S+)`)
+)
+
+// SampleCommand encodes duration seconds of the input from offset once per
+// profile at a constant rate factor, writing profile i to outputs[i]. It
+// uses the scaling and encoder of each profile, so the size of a sample
+// predicts the bitrate the full encode needs for the same quality.
+func SampleCommand(inputPath string, offset, duration float64, profiles []config.QualityProfile, source *VideoInfo, crf int, outputs []string) []string {
+	args := []string{"ffmpeg", "-y", "-ss", formatSeconds(offset), "-t", formatSeconds(duration), "-i", inputPath, "-an"}
+
+	var filter strings.Builder
+	fmt.Fprintf(&filter, "[0:v]split=%d", len(profiles))
+	for i := range profiles {
+		fmt.Fprintf(&filter, "[v%d]", i)
+	}
+	for i, p := range profiles {
+		fmt.Fprintf(&filter, ";[v%d]%s[out%d]", i, scaleFilter(p, source), i)
+	}
+	args = append(args, "-filter_complex", filter.String())
+
+	for i, p := range profiles {
+		args = append(args, "-map", "[out"+strconv.Itoa(i)+"]", "-c:v", p.VideoCodec, "-crf", strconv.Itoa(crf))
+		switch p.VideoCodec {
+		case "libaom-av1":
+			// libaom only runs in constant quality mode without a bitrate.
+			args = append(args, "-b:v", "0")
+			if p.Preset != "" {
+				args = append(args, "-cpu-used", p.Preset)
+			}
+		default:
+			if p.Preset != "" {
+				args = append(args, "-preset", p.Preset)
+			}
+		}
+		args = append(args, outputs[i])
+	}
+	return args
+}
+
+// QualityCommand compares an encoded sample with the same span of the
+// source, scaled like p, and logs the PSNR and SSIM ParseQuality reads.
+func QualityCommand(samplePath, inputPath string, offset, duration float64, p config.QualityProfile, source *VideoInfo) []string {
+	filter := fmt.Sprintf(
+		"[1:v]%s[ref];[0:v]setsar=1[dist];[dist]split[d1][d2];[ref]split[r1][r2];[d1][r1]psnr;[d2][r2]ssim",
+		scaleFilter(p, source),
+	)
+	return []string{
+		"ffmpeg", "-hide_banner", "-nostats",
+		"-i", samplePath,
+		"-ss", formatSeconds(offset), "-t", formatSeconds(duration), "-i", inputPath,
+		"-lavfi", filter,
+		"-f", "null", "-",
+	}
+}
+
+// maxPSNR stands in for the infinite PSNR ffmpeg reports for identical
+// frames.
+const maxPSNR = 100
+
+// ParseQuality reads the average PSNR in dB and the overall SSIM from the
+// log of QualityCommand.
+func ParseQuality(log string) (float64, float64, error) {
+	psnrMatch := psnrAverage.FindStringSubmatch(log)
+	ssimMatch := ssimAll.FindStringSubmatch(log)
+	if psnrMatch == nil || ssimMatch == nil {
+		return 0, 0, fmt.Errorf("no psnr or ssim summary in ffmpeg output")
+	}
+	psnr := float64(maxPSNR)
+	if psnrMatch[1] != "inf" {
+		var err error
+		if psnr, err = strconv.ParseFloat(psnrMatch[1], 64); err != nil {
+			return 0, 0, fmt.Errorf("parse psnr: %w", err)
+		}
+		psnr = min(psnr, maxPSNR)
+	}
+	ssim, err := strconv.ParseFloat(ssimMatch[1], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse ssim: %w", err)
+	}
+	return psnr, ssim, nil
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
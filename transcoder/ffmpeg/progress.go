@@ -0,0 +1,54 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProgressArgs makes ffmpeg write machine-readable progress to stdout
+// instead of the interactive stats line.
+var ProgressArgs = []string{"-progress", "pipe:1", "-nostats"}
+
+// WithProgress inserts ProgressArgs right after the ffmpeg binary.
+func WithProgress(args []string) []string {
+	out := make([]string, 0, len(args)+len(ProgressArgs))
+	out = append(out, args[0])
+	out = append(out, ProgressArgs...)
+	return append(out, args[1:]...)
+}
+
+// ParseProgress reads the key=value blocks written by -progress and calls fn
+// with the encoded position at the end of every block. done is true for the
+// final block.
+func ParseProgress(r io.Reader, fn func(outTime time.Duration, done bool)) error {
+	var outTime time.Duration
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "out_time_us":
+			// out_time_ms is microseconds too, so only out_time_us is read.
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+				outTime = time.Duration(us) * time.Microsecond
+			}
+		case "progress":
+			fn(outTime, value == "end")
+		}
+	}
+	return scanner.Err()
+}
+
+// Percent converts an encoded position into a 0-100 percentage of duration.
+func Percent(outTime time.Duration, durationSec float64) int {
+	if durationSec <= 0 {
+		return 0
+	}
+	pct := int(outTime.Seconds() / durationSec * 100)
+	return max(0, min(pct, 100))
+}
@@ -0,0 +1,98 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	// SpriteTrack is the WebVTT file written next to the sprite sheets.
+	SpriteTrack = "thumbnails.vtt"
+	// spritePattern names the sprite sheets; image2 numbers them from 1.
+	spritePattern = "sprite_%03d.jpg"
+)
+
+// SpriteLayout describes how trick-play thumbnails are tiled into sheets.
+type SpriteLayout struct {
+	IntervalSec int
+	Columns     int
+	Rows        int
+	// TileWidth and TileHeight are the size of a single thumbnail.
+	TileWidth  int
+	TileHeight int
+}
+
+// NewSpriteLayout sizes tiles to tileWidth, keeping the display aspect ratio
+// of the source.
+func NewSpriteLayout(intervalSec, columns, rows, tileWidth int, source *VideoInfo) SpriteLayout {
+	layout := SpriteLayout{
+		IntervalSec: intervalSec,
+		Columns:     columns,
+		Rows:        rows,
+		TileWidth:   even(tileWidth),
+		TileHeight:  even(tileWidth * 9 / 16),
+	}
+	if source != nil {
+		if stream := source.VideoStream(); stream != nil {
+			if w, h := stream.DisplaySize(); w > 0 && h > 0 {
+				layout.TileHeight = max(even(int(math.Round(float64(tileWidth*h)/float64(w)))), 2)
+			}
+		}
+	}
+	return layout
+}
+
+// SpriteCommand samples one frame every IntervalSec and tiles the frames into
+// Columns x Rows JPEG sheets in outputDir.
+func SpriteCommand(inputPath, outputDir string, layout SpriteLayout) []string {
+	filter := fmt.Sprintf("fps=1/%d,scale=%d:%d,tile=%dx%d",
+		layout.IntervalSec, layout.TileWidth, layout.TileHeight, layout.Columns, layout.Rows)
+	return []string{"ffmpeg",
+		"-y",
+		"-i", inputPath,
+		"-an",
+		"-vf", filter,
+		"-q:v", "4",
+		outputDir + "/" + spritePattern,
+	}
+}
+
+// WriteSpriteTrack writes a WebVTT track mapping each interval of the video
+// to its tile in the sheets produced by SpriteCommand, using media fragment
+// "#xywh" coordinates.
+func WriteSpriteTrack(w io.Writer, layout SpriteLayout, durationSec float64) error {
+	if _, err := io.WriteString(w, "WEBVTT\n"); err != nil {
+		return err
+	}
+	perSheet := layout.Columns * layout.Rows
+	interval := float64(layout.IntervalSec)
+	for i := 0; float64(i)*interval < durationSec; i++ {
+		start := float64(i) * interval
+		end := math.Min(start+interval, durationSec)
+		tile := i % perSheet
+		_, err := fmt.Fprintf(w, "\n%s --> %s\n"+spritePattern+"#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(start), vttTimestamp(end),
+			i/perSheet+1,
+			(tile%layout.Columns)*layout.TileWidth, (tile/layout.Columns)*layout.TileHeight,
+			layout.TileWidth, layout.TileHeight,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vttTimestamp formats seconds as a WebVTT HH:MM:SS.mmm timestamp.
+func vttTimestamp(sec float64) string {
+	d := time.Duration(math.Round(sec*1000)) * time.Millisecond
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, d/time.Millisecond)
+}
@@ -1,18 +1,24 @@
 package ffmpeg
 
 import (
-	"os/exec"
+	"context"
 )
 
-func GenerateThumbnail(input string, output string, timestamp string) error {
-	cmd := exec.Command("ffmpeg",
+// thumbnailWidth bounds the long side of generated thumbnails.
+const thumbnailWidth = "640"
+
+// GenerateThumbnail extracts a single frame at timestamp, scaled to fit
+// thumbnailWidth without changing the aspect ratio.
+func GenerateThumbnail(ctx context.Context, input string, output string, timestamp string) error {
+	cmd := Command(ctx, []string{"ffmpeg",
+		"-y",
+		"-ss", timestamp, // Seek before -i so only the target frame is decoded
 		"-i", input,
-		"-ss", timestamp, // Timestamp (e.g., "00:00:05")
-		"-vframes", "1", // Single frame
-		"-vf", "scale=640:360", // Thumbnail size
+		"-frames:v", "1", // Single frame
+		"-vf", "scale=w=" + thumbnailWidth + ":h=" + thumbnailWidth + ":force_original_aspect_ratio=decrease:force_divisible_by=2",
 		"-q:v", "2", // Quality
 		output,
-	)
+	})
 
 	return cmd.Run()
 }
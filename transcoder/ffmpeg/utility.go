@@ -1,98 +1,152 @@
 package ffmpeg
 
-func HlsCommand(inputPath, outputDir string) []string {
-	return []string{
-		"ffmpeg",
-		"-i", inputPath,
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/config"
+)
+
+const (
+	// segmentDuration is the target segment length in seconds.
+	segmentDuration = 6
+	defaultGOPSize  = "48"
+)
+
+const (
+	// DashManifest and HLSMaster are the manifest names in the output dir.
+	DashManifest = "manifest.mpd"
+	HLSMaster    = "master.m3u8"
+)
+
+// CmafCommand encodes the ladder once into fragmented MP4 (CMAF) segments
+// and writes both DashManifest and, through -hls_playlist, one HLS media
+// playlist per stream. The master playlist ffmpeg writes lacks the audio
+// codec, so it is replaced by WriteHLSMaster afterwards.
+func CmafCommand(inputPath, outputDir string, profiles []config.QualityProfile, source *VideoInfo) []string {
+	args := []string{"ffmpeg", "-i", inputPath}
+	args = append(args, videoLadderArgs(profiles, source)...)
+	args = append(args, audioLadderArgs(profiles)...)
+
+	return append(args,
+		"-use_timeline", "1",
+		"-use_template", "1",
+		// Keep every segment in the manifests; this is VOD, not a live window.
+		"-window_size", "0",
+		"-seg_duration", strconv.Itoa(segmentDuration),
+		"-adaptation_sets", adaptationSets(profiles),
+		"-dash_segment_type", "mp4",
+		"-hls_playlist", "1",
 
-		"-filter_complex",
-		"[0:v]split=3[v1][v2][v3];" +
-			"[v1]scale=640:360:flags=fast_bilinear[360p];" +
-			"[v2]scale=1280:720:flags=fast_bilinear[720p];" +
-			"[v3]scale=1920:1080:flags=fast_bilinear[1080p]",
+		"-f", "dash",
+		outputDir+"/"+DashManifest,
+	)
+}
 
-		// 360p video stream
-		"-map", "[360p]",
-		"-map", "[720p]",
-		"-map", "[1080p]",
+// videoLadderArgs splits the input video into one scaled output stream per
+// profile and sets the per-stream encoder settings.
+func videoLadderArgs(profiles []config.QualityProfile, source *VideoInfo) []string {
+	var filter strings.Builder
+	fmt.Fprintf(&filter, "[0:v]split=%d", len(profiles))
+	for i := range profiles {
+		fmt.Fprintf(&filter, "[v%d]", i)
+	}
+	for i, p := range profiles {
+		fmt.Fprintf(&filter, ";[v%d]%s[out%d]", i, scaleFilter(p, source), i)
+	}
 
-		"-c:v", "libx264",
-		"-preset", "veryfast",
-		"-profile:v", "high",
-		"-level:v", "4.1",
+	args := []string{"-filter_complex", filter.String()}
+	for i, p := range profiles {
+		args = append(args, "-map", "[out"+strconv.Itoa(i)+"]")
+		args = append(args, encoderArgs(strconv.Itoa(i), p)...)
+	}
 
-		"-g", "48",
-		"-keyint_min", "48",
+	gop := gopSize(source)
+	return append(args,
+		"-g", gop,
+		"-keyint_min", gop,
 		"-sc_threshold", "0",
+	)
+}
 
-		"-b:v:0", "1000k",
-		"-b:v:1", "4000k",
-		"-b:v:2", "8000k",
-
-		"-f", "hls",
-		"-hls_time", "6",
-		"-hls_playlist_type", "vod",
-		"-hls_flags", "independent_segments",
-		"-hls_segment_type", "mpegts",
-		"-hls_list_size", "0",
-
-		"-master_pl_name", "master.m3u8",
-		"-var_stream_map", "v:0 v:1 v:2",
-
-		"-hls_segment_filename",
-		outputDir + "/%v/segment_%03d.ts",
-
-		outputDir + "/%v/playlist.m3u8",
+// encoderArgs sets the encoder, rate control and encoder specific options
+// of the video output stream idx. Keyframes are forced every GOP by the
+// shared -g, so scene-cut keyframes are turned off where the generic
+// -sc_threshold does not reach the encoder.
+func encoderArgs(idx string, p config.QualityProfile) []string {
+	bufsize := strconv.Itoa(2*p.VideoBitrateKbps()) + "k"
+	args := []string{
+		"-c:v:" + idx, p.VideoCodec,
+		"-b:v:" + idx, p.VideoBitrate,
+		"-maxrate:v:" + idx, p.VideoBitrate,
+		"-bufsize:v:" + idx, bufsize,
+	}
+	switch p.VideoCodec {
+	case "libx264":
+		args = append(args, "-preset:v:"+idx, p.Preset, "-profile:v:"+idx, "high")
+	case "libx265":
+		if p.Preset != "" {
+			args = append(args, "-preset:v:"+idx, p.Preset)
+		}
+		// hvc1 keeps parameter sets in the sample entry, as Apple
+		// devices require for HLS.
+		args = append(args,
+			"-tag:v:"+idx, "hvc1",
+			"-x265-params:v:"+idx, "scenecut=0:open-gop=0:log-level=error",
+		)
+	case "libsvtav1":
+		if p.Preset != "" {
+			args = append(args, "-preset:v:"+idx, p.Preset)
+		}
+		args = append(args, "-svtav1-params:v:"+idx, "scd=0")
+	case "libaom-av1":
+		if p.Preset != "" {
+			args = append(args, "-cpu-used:v:"+idx, p.Preset)
+		}
+		args = append(args, "-row-mt:v:"+idx, "1")
+	default:
+		args = append(args, "-preset:v:"+idx, p.Preset)
 	}
+	return args
 }
 
-func DashCommand(inputPath, outputDir string) []string {
-	return []string{
-		"ffmpeg",
-		"-i", inputPath,
-
-		"-filter_complex",
-		"[0:v]split=3[v1][v2][v3];" +
-			"[v1]scale=640:360:flags=fast_bilinear[360p];" +
-			"[v2]scale=1280:720:flags=fast_bilinear[720p];" +
-			"[v3]scale=1920:1080:flags=fast_bilinear[1080p]",
-
-		// 360p
-		"-map", "[360p]",
-		"-c:v:0", "libx264",
-		"-b:v:0", "1000k",
-
-		// 720p
-		"-map", "[720p]",
-		"-c:v:1", "libx264",
-		"-b:v:1", "4000k",
-
-		// 1080p
-		"-map", "[1080p]",
-		"-c:v:2", "libx264",
-		"-b:v:2", "8000k",
-
-		// Shared video settings
-		"-preset", "veryfast",
-		"-profile:v", "high",
-		"-level:v", "4.1",
-		"-g", "48",
-		"-keyint_min", "48",
-		"-sc_threshold", "0",
-
-		// Audio
-		"-map", "a:0?",
-		"-c:a", "aac",
-		"-b:a", "128k",
-
-		// DASH settings
-		"-use_timeline", "1",
-		"-use_template", "1",
-		"-window_size", "5",
-		"-seg_duration", "6",
-		"-adaptation_sets", "id=0,streams=v id=1,streams=a",
+// adaptationSets puts the video streams of each codec family into an
+// adaptation set of their own, followed by one audio set. It relies on
+// the grouping of ExpandCodecs.
+func adaptationSets(profiles []config.QualityProfile) string {
+	var sets []string
+	var streams []string
+	for i, p := range profiles {
+		streams = append(streams, strconv.Itoa(i))
+		if i+1 == len(profiles) || codecGroup(profiles[i+1]) != codecGroup(p) {
+			sets = append(sets, fmt.Sprintf("id=%d,streams=%s", len(sets), strings.Join(streams, ",")))
+			streams = nil
+		}
+	}
+	if len(sets) == 1 {
+		sets[0] = "id=0,streams=v"
+	}
+	return strings.Join(append(sets, fmt.Sprintf("id=%d,streams=a", len(sets))), " ")
+}
 
-		"-f", "dash",
-		outputDir + "/manifest.mpd",
+// audioLadderArgs maps one audio rendition per distinct codec and bitrate
+// found in the profiles. Inputs without audio are skipped.
+func audioLadderArgs(profiles []config.QualityProfile) []string {
+	var args []string
+	seen := make(map[string]bool)
+	for _, p := range profiles {
+		key := p.AudioCodec + "@" + p.AudioBitrate
+		if seen[key] {
+			continue
+		}
+		idx := strconv.Itoa(len(seen))
+		seen[key] = true
+		args = append(args,
+			"-map", "a:0?",
+			"-c:a:"+idx, p.AudioCodec,
+			"-b:a:"+idx, p.AudioBitrate,
+		)
 	}
+	return args
 }
@@ -2,12 +2,20 @@ package main
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/joho/godotenv/autoload"
 	"gitlab.com/subrotokumar/playstack/transcoder/service"
 )
 
 func main() {
+	// SIGTERM is sent on container eviction and scale-in. Cancelling ctx
+	// kills ffmpeg and hands the job back to the queue.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	worker := service.New()
-	worker.Run(context.Background())
+	worker.Run(ctx)
 }
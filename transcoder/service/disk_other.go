@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || dragonfly)
+
+package service
+
+import "errors"
+
+func freeDiskSpace(string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
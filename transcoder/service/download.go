@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/errgroup"
+)
+
+// downloadSpaceFactor is how many times the source size must be free before
+// a download starts, leaving room for the renditions written next to it.
+const downloadSpaceFactor = 2
+
+var (
+	ErrInsufficientDisk = errors.New("insufficient disk space")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// Download fetches the job source into destPath using parallel byte-range
+// requests of Transfer.PartSizeMB. Each range is retried on its own, and
+// the finished file is verified against the object ETag.
+func (s *Service) Download(ctx context.Context, job *Job, destPath string) error {
+	s.log.Info("Downloading file", "path", destPath)
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return fmt.Errorf("create dir: %w", err)
+	}
+
+	head, err := s.headSource(ctx, job, nil)
+	if err != nil {
+		return fmt.Errorf("head object failed: %w", err)
+	}
+	size := aws.ToInt64(head.ContentLength)
+	if size == 0 {
+		size = job.ObjectSize()
+	}
+	if err := checkFreeSpace(filepath.Dir(destPath), size*downloadSpaceFactor); err != nil {
+		return err
+	}
+
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer file.Close()
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("allocate file: %w", err)
+	}
+
+	chunkSize := max(s.cfg.Transfer.PartSizeMB, 1) * mb
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(s.cfg.Transfer.Concurrency, 1))
+	for offset := int64(0); offset < size; offset += chunkSize {
+		length := min(chunkSize, size-offset)
+		g.Go(func() error {
+			return retry(gctx, s.cfg.Transfer.MaxAttempts, func() error {
+				err := s.downloadRange(gctx, job, head.ETag, file, offset, length)
+				if err != nil {
+					s.log.Warn("Range download failed", "offset", offset, "length", length, "err", err)
+				}
+				return err
+			})
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("download ranges: %w", err)
+	}
+
+	if s.cfg.Transfer.VerifyChecksum {
+		if err := s.verifyETag(ctx, job, file, aws.ToString(head.ETag)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// headSource returns the object metadata, or that of a single part when
+// partNumber is set.
+func (s *Service) headSource(ctx context.Context, job *Job, partNumber *int32) (head *s3.HeadObjectOutput, err error) {
+	err = retry(ctx, s.cfg.Transfer.MaxAttempts, func() error {
+		head, err = s.storage.Client().HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:     aws.String(job.Bucket()),
+			Key:        aws.String(job.Key()),
+			PartNumber: partNumber,
+		})
+		return err
+	})
+	return head, err
+}
+
+// downloadRange writes length bytes at offset of the object to file. IfMatch
+// pins every range to the same object version.
+func (s *Service) downloadRange(ctx context.Context, job *Job, etag *string, file *os.File, offset, length int64) error {
+	out, err := s.storage.Client().GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(job.Bucket()),
+		Key:     aws.String(job.Key()),
+		Range:   aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+		IfMatch: etag,
+	})
+	if err != nil {
+		return err
+	}
+	defer out.Body.Close()
+
+	n, err := io.Copy(io.NewOffsetWriter(file, offset), io.LimitReader(out.Body, length))
+	if err != nil {
+		return err
+	}
+	if n != length {
+		return fmt.Errorf("short read at %d: got %d of %d bytes", offset, n, length)
+	}
+	return nil
+}
+
+// verifyETag recomputes the S3 ETag of file. Single-part objects carry the
+// MD5 of the content; multipart objects carry the MD5 of the concatenated
+// part MD5s followed by "-<parts>", which needs the original part size.
+func (s *Service) verifyETag(ctx context.Context, job *Job, file *os.File, etag string) error {
+	etag = strings.Trim(etag, `"`)
+	if etag == "" {
+		s.log.Warn("Source has no ETag, skipping checksum")
+		return nil
+	}
+
+	var partSize int64
+	if _, count, ok := strings.Cut(etag, "-"); ok {
+		if _, err := strconv.Atoi(count); err != nil {
+			s.log.Warn("Unrecognized ETag, skipping checksum", "etag", etag)
+			return nil
+		}
+		head, err := s.headSource(ctx, job, aws.Int32(1))
+		if err != nil {
+			return fmt.Errorf("head first part: %w", err)
+		}
+		partSize = aws.ToInt64(head.ContentLength)
+	}
+
+	sum, err := fileETag(file, partSize)
+	if err != nil {
+		return fmt.Errorf("checksum: %w", err)
+	}
+	if sum != etag {
+		return fmt.Errorf("%w: etag %s, downloaded %s", ErrChecksumMismatch, etag, sum)
+	}
+	s.log.Info("Verified download checksum", "etag", etag)
+	return nil
+}
+
+// fileETag computes the S3 ETag of file, split into partSize parts when
+// partSize is positive.
+func fileETag(file *os.File, partSize int64) (string, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if partSize <= 0 {
+		h := md5.New()
+		if _, err := io.Copy(h, file); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	var digests []byte
+	parts := 0
+	for {
+		h := md5.New()
+		n, err := io.CopyN(h, file, partSize)
+		if n > 0 {
+			digests = h.Sum(digests)
+			parts++
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	sum := md5.Sum(digests)
+	return hex.EncodeToString(sum[:]) + "-" + strconv.Itoa(parts), nil
+}
+
+// checkFreeSpace fails when the file system holding dir has less than need
+// bytes available. Platforms without a free-space query skip the check.
+func checkFreeSpace(dir string, need int64) error {
+	free, err := freeDiskSpace(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat file system: %w", err)
+	}
+	if free < uint64(need) {
+		return fmt.Errorf("%w: need %d bytes, %d available", ErrInsufficientDisk, need, free)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
+)
+
+// hlsDir and dashDir hold the encrypted HLS and DASH outputs, relative to
+// the job output. Each format is encrypted with its own scheme, so they
+// cannot share the clear CMAF segments in the root.
+const (
+	hlsDir  = "hls"
+	dashDir = "dash"
+)
+
+// Content key schemes, as stored by the API.
+const (
+	SchemeAES128 = "AES-128"
+	SchemeCENC   = "CENC"
+)
+
+// ContentKeyRequest registers a content key with the API. Key is sent as
+// base64.
+type ContentKeyRequest struct {
+	UserID string `json:"user_id"`
+	Scheme string `json:"scheme"`
+	KeyID  string `json:"key_id"`
+	Key    []byte `json:"key"`
+}
+
+// EncryptHLS writes the HLS output of the job, encrypted with a new random
+// key, to hlsDir.
+func (s *Service) EncryptHLS(ctx context.Context, job *Job, outputDir string) error {
+	keyID, key, err := s.newContentKey(ctx, job, SchemeAES128)
+	if err != nil {
+		return err
+	}
+	defer clear(key)
+
+	uri := s.keyURI(job, "key") + "?kid=" + keyID
+	if err := ffmpeg.EncryptHLS(outputDir, filepath.Join(outputDir, hlsDir), key, uri); err != nil {
+		return err
+	}
+	job.EncryptedHLS = true
+	s.log.Info("Encrypted HLS segments", "scheme", SchemeAES128, "key_id", keyID)
+	return nil
+}
+
+// EncryptDASH repackages the DASH output of the job into dashDir with CENC
+// under a new random key ID and key, and adds the ContentProtection
+// elements that point players to the ClearKey license endpoint. The joined
+// streams it reads are written to scratchDir and removed afterwards.
+func (s *Service) EncryptDASH(ctx context.Context, job *Job, outputDir, scratchDir string) error {
+	keyID, key, err := s.newContentKey(ctx, job, SchemeCENC)
+	if err != nil {
+		return err
+	}
+	defer clear(key)
+	defer os.RemoveAll(scratchDir)
+
+	streams, err := ffmpeg.JoinSegments(outputDir, scratchDir)
+	if err != nil {
+		return fmt.Errorf("join segments: %w", err)
+	}
+	dir := filepath.Join(outputDir, dashDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	kid := uuid.MustParse(keyID)
+	cmd := ffmpeg.Command(ctx, ffmpeg.CencCommand(streams, dir, job.Profiles, key, kid[:]))
+	var output bytes.Buffer
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("ffmpeg interrupted: %w", context.Cause(ctx))
+		}
+		return fmt.Errorf("ffmpeg %w: %s", err, tail(output.String(), ffmpegErrorLines))
+	}
+	if err := ffmpeg.FixCodecs(dir); err != nil {
+		return fmt.Errorf("fix codecs: %w", err)
+	}
+	if err := ffmpeg.AddContentProtection(filepath.Join(dir, ffmpeg.DashManifest), keyID, s.keyURI(job, "license")); err != nil {
+		return err
+	}
+	job.EncryptedDASH = true
+	s.log.Info("Encrypted DASH segments", "scheme", SchemeCENC, "key_id", keyID)
+	return nil
+}
+
+// newContentKey generates a random key and key ID for scheme and registers
+// them with the API before any segment is encrypted. The key only leaves
+// the process in that request; it is never written to disk, the outbox or
+// the media bucket. Callers clear the key when done.
+//
+// Each job registers new keys next to those of earlier jobs, so segments
+// already published stay playable until the output report, which carries
+// job.KeyIDs, switches the video to this job's outputs.
+func (s *Service) newContentKey(ctx context.Context, job *Job, scheme string) (string, []byte, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", nil, err
+	}
+	keyID := uuid.NewString()
+	if err := s.RegisterContentKey(ctx, job, scheme, keyID, key); err != nil {
+		clear(key)
+		return "", nil, fmt.Errorf("register key: %w", err)
+	}
+	job.KeyIDs = append(job.KeyIDs, keyID)
+	return keyID, key, nil
+}
+
+// RegisterContentKey stores the content key of the job's video with the
+// API. Unlike other callbacks it is never queued in the outbox, so a key
+// the API did not receive fails the job.
+func (s *Service) RegisterContentKey(ctx context.Context, job *Job, scheme, keyID string, key []byte) error {
+	userID, videoID := job.UserAndVideoID()
+	request := ContentKeyRequest{UserID: userID, Scheme: scheme, KeyID: keyID, Key: key}
+	return s.notify(ctx, http.MethodPut, "/internal/media/videos/"+videoID+"/keys", request, nil)
+}
+
+// keyURI is the API endpoint, "key" or "license", players fetch the
+// decryption key of the job from.
+func (s *Service) keyURI(job *Job, endpoint string) string {
+	_, videoID := job.UserAndVideoID()
+	return strings.TrimRight(s.cfg.Encryption.KeyBaseURL, "/") + "/media/videos/" + videoID + "/" + endpoint
+}
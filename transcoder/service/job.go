@@ -0,0 +1,134 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"gitlab.com/subrotokumar/playstack/libs/storage"
+	"gitlab.com/subrotokumar/playstack/transcoder/config"
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
+)
+
+var (
+	ErrEmptyEvent = errors.New("s3 event has no records")
+	ErrInvalidKey = errors.New("s3 key is not videos/<user>/<video>/video.<ext>")
+)
+
+// sourceExtensions is the allow-list of source containers, matching the
+// formats the API accepts for uploads.
+var sourceExtensions = []string{".mp4", ".mov", ".mkv", ".webm", ".avi"}
+
+// Job is a single transcoding request, built from an S3 upload event.
+type Job struct {
+	Event storage.S3Event
+	// ReceiptHandle is set when the job was received from SQS.
+	ReceiptHandle string
+	// ID and Attempt identify the transcoding job row tracked by the API.
+	ID      string
+	Attempt int32
+	// Republish is set when the video was already published as the job
+	// started, e.g. for an admin reprocess. The job then never changes the
+	// video status and uploads to a prefix of its own, so viewers keep
+	// playing the old outputs until the new ones are reported.
+	Republish bool
+
+	// Source is the probe result of the downloaded input.
+	Source *ffmpeg.VideoInfo
+	// Rungs is the ladder selected for this source by Analyze.
+	Rungs []config.QualityProfile
+	// Profiles is the ladder that is encoded: Rungs after per-title
+	// tuning, with codec variants expanded. Its order is the order of the
+	// output streams. Ladder records how it was chosen.
+	Profiles []config.QualityProfile
+	Ladder   *Ladder
+	// PosterKey and ThumbnailKeys are the uploaded poster candidates.
+	PosterKey     string
+	ThumbnailKeys []string
+	// SpriteTrack reports whether trick-play sprites were generated.
+	SpriteTrack bool
+	// Packaging lists the manifest formats to publish, see ParsePackaging.
+	Packaging []string
+	// EncryptedHLS and EncryptedDASH report whether the output of the
+	// format was written encrypted to hlsDir or dashDir.
+	EncryptedHLS  bool
+	EncryptedDASH bool
+	// KeyIDs lists the content keys registered for the output. The API
+	// serves them once the output report activates them.
+	KeyIDs []string
+	// OutputKeys lists the objects Upload stored. OutputsReported is set
+	// once the API took the output report, after which the old outputs are
+	// no longer served.
+	OutputKeys      []string
+	OutputsReported bool
+}
+
+func NewJob(body string) (*Job, error) {
+	var event storage.S3Event
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return nil, err
+	}
+	if len(event.Records) == 0 {
+		return nil, ErrEmptyEvent
+	}
+	job := &Job{Event: event}
+	parts := strings.Split(job.Key(), "/")
+	if len(parts) != 4 || parts[0] != "videos" || parts[1] == "" || parts[2] == "" || !strings.HasPrefix(parts[3], "video.") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKey, job.Key())
+	}
+	return job, nil
+}
+
+func (j *Job) Bucket() string {
+	return j.Event.Records[0].S3.Bucket.Name
+}
+
+func (j *Job) Key() string {
+	return j.Event.Records[0].S3.Object.Key
+}
+
+func (j *Job) UserAndVideoID() (string, string) {
+	keys := strings.Split(j.Key(), "/")
+	return keys[1], keys[2]
+}
+
+// Packages reports whether the job publishes the packaging format.
+func (j *Job) Packages(format string) bool {
+	return slices.Contains(j.Packaging, format)
+}
+
+// Extension returns the lower-cased extension of the source key, e.g. ".mov".
+func (j *Job) Extension() string {
+	return strings.ToLower(path.Ext(j.Key()))
+}
+
+// CheckContainer rejects sources whose extension is not an accepted
+// container.
+func (j *Job) CheckContainer() error {
+	if !slices.Contains(sourceExtensions, j.Extension()) {
+		return reject(ReasonUnsupportedContainer, "container %q is not one of %s", j.Extension(), strings.Join(sourceExtensions, ", "))
+	}
+	return nil
+}
+
+// OutputRoot is the media bucket prefix holding every output of the video.
+func (j *Job) OutputRoot() string {
+	userID, videoID := j.UserAndVideoID()
+	return fmt.Sprintf("videos/%s/%s/output/", userID, videoID)
+}
+
+// OutputPrefix is the media bucket prefix the job's outputs are uploaded to.
+// A republish gets one per attempt, next to the outputs still served.
+func (j *Job) OutputPrefix() string {
+	if j.Republish {
+		return fmt.Sprintf("%s%d/", j.OutputRoot(), j.Attempt)
+	}
+	return j.OutputRoot()
+}
+
+func (j *Job) ObjectSize() int64 {
+	return j.Event.Records[0].S3.Object.Size
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package service
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile cannot lock files here, so workspaces are never swept.
+func lockFile(*os.File) error {
+	return errors.ErrUnsupported
+}
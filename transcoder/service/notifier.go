@@ -4,30 +4,100 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/google/uuid"
 	"gitlab.com/subrotokumar/playstack/libs/db"
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
 )
 
 type (
 	UpdateMetadataRequest struct {
-		Title       *string        `json:"title"`
-		Status      db.VideoStatus `json:"status" validate:"omitempty,oneof='PREUPLOAD' 'UPLOADED' 'PROCESSING' 'READY' 'FAILED'"`
-		DurationSec *int32         `json:"duration_sec"`
+		Title         *string          `json:"title"`
+		Status        db.VideoStatus   `json:"status" validate:"omitempty,oneof='PREUPLOAD' 'UPLOADED' 'PROCESSING' 'READY' 'FAILED'"`
+		DurationSec   *int32           `json:"duration_sec"`
+		Progress      *int16           `json:"progress"`
+		PosterKey     *string          `json:"poster_key"`
+		ThumbnailKeys []string         `json:"thumbnail_keys"`
+		Metadata      *ffmpeg.Metadata `json:"metadata"`
+		FailureReason RejectReason     `json:"failure_reason"`
+	}
+
+	Rendition struct {
+		Resolution  string `json:"resolution"`
+		BitrateKbps int32  `json:"bitrate_kbps"`
+		S3Key       string `json:"s3_key"`
+		Codec       string `json:"codec,omitempty"`
+	}
+	Manifest struct {
+		Type  string `json:"type"`
+		S3Key string `json:"s3_key"`
+	}
+	UpdateOutputsRequest struct {
+		UserID     string      `json:"user_id"`
+		Renditions []Rendition `json:"renditions"`
+		Manifests  []Manifest  `json:"manifests"`
+		KeyIDs     []string    `json:"key_ids,omitempty"`
+	}
+
+	UpdateJobRequest struct {
+		Status       db.JobStatus `json:"status"`
+		ErrorMessage *string      `json:"error_message,omitempty"`
+		Ladder       *Ladder      `json:"ladder,omitempty"`
+	}
+	JobResponse struct {
+		Data struct {
+			ID          string          `json:"id"`
+			Attempt     int32           `json:"attempt"`
+			PriorStatus *db.VideoStatus `json:"prior_status"`
+		} `json:"data"`
 	}
 )
 
-func (s *Service) UpdateMetadata(ctx context.Context, request UpdateMetadataRequest) error {
+// UpdateMetadata patches the video row. It is durable: when the API stays
+// unreachable the update is queued in the outbox and nil is returned.
+func (s *Service) UpdateMetadata(ctx context.Context, job *Job, request UpdateMetadataRequest) error {
 	s.log.Info("Updating video metadata in database")
 
-	userID, videoID := s.cfg.UserAndVideoID()
+	_, videoID := job.UserAndVideoID()
+	if err := s.notifyDurable(ctx, http.MethodPatch, "/internal/media/videos/"+videoID, metadataPayload(job, request)); err != nil {
+		return err
+	}
+	s.log.Info("Notifier updated metadata", "status", request.Status)
+	return nil
+}
+
+// ReportProgress sends a PROCESSING update with the encode percentage. It
+// makes a single attempt since the next report supersedes it, and is
+// skipped while transitions wait in the outbox so it cannot overtake them.
+// A republish reports none, since it would take the video offline.
+func (s *Service) ReportProgress(ctx context.Context, job *Job, percent int16) error {
+	if job.Republish {
+		return nil
+	}
+	// The lock only guards the check, so a slow API does not hold up the
+	// outbox for the length of the request.
+	s.outbox.mu.Lock()
+	names, err := s.outbox.list()
+	s.outbox.mu.Unlock()
+	if err != nil || len(names) > 0 {
+		return err
+	}
+	_, videoID := job.UserAndVideoID()
+	request := UpdateMetadataRequest{Status: db.VideoStatusPROCESSING, Progress: &percent}
+	return s.send(ctx, http.MethodPatch, "/internal/media/videos/"+videoID, metadataPayload(job, request), uuid.NewString(), nil)
+}
 
-	url := s.cfg.NotifierService.URL + "/internal/media/videos/" + videoID
+func metadataPayload(job *Job, request UpdateMetadataRequest) map[string]any {
+	userID, _ := job.UserAndVideoID()
 	payload := make(map[string]any)
 	payload["user_id"] = userID
-	payload["status"] = string(request.Status)
+	if request.Status != "" {
+		payload["status"] = string(request.Status)
+	}
 
 	if request.Title != nil {
 		payload["title"] = *request.Title
@@ -35,39 +105,190 @@ func (s *Service) UpdateMetadata(ctx context.Context, request UpdateMetadataRequ
 	if request.DurationSec != nil {
 		payload["duration_sec"] = *request.DurationSec
 	}
+	if request.Progress != nil {
+		payload["progress"] = *request.Progress
+	}
+	if request.PosterKey != nil {
+		payload["poster_key"] = *request.PosterKey
+	}
+	if len(request.ThumbnailKeys) > 0 {
+		payload["thumbnail_keys"] = request.ThumbnailKeys
+	}
+	if request.Metadata != nil {
+		payload["metadata"] = request.Metadata
+	}
+	if request.FailureReason != "" {
+		payload["failure_reason"] = string(request.FailureReason)
+	}
+	return payload
+}
+
+// ReportOutputs records the uploaded renditions and manifests of a job.
+func (s *Service) ReportOutputs(ctx context.Context, job *Job, request UpdateOutputsRequest) error {
+	s.log.Info("Reporting video outputs", "renditions", len(request.Renditions), "manifests", len(request.Manifests))
 
-	bodyBytes, err := json.Marshal(payload)
+	userID, videoID := job.UserAndVideoID()
+	request.UserID = userID
+
+	return s.notifyDurable(ctx, http.MethodPut, "/internal/media/videos/"+videoID+"/outputs", request)
+}
+
+// CreateJob records a new processing attempt and stores its ID and attempt
+// number on the job. Jobs from a polled message are marked queue managed,
+// since SQS redelivers their message if this worker dies. A video that
+// was published when the job started makes it a republish.
+func (s *Service) CreateJob(ctx context.Context, job *Job) error {
+	userID, videoID := job.UserAndVideoID()
+
+	var response JobResponse
+	request := map[string]any{"user_id": userID, "queue_managed": job.ReceiptHandle != ""}
+	if err := s.notify(ctx, http.MethodPost, "/internal/media/videos/"+videoID+"/jobs", request, &response); err != nil {
+		return err
+	}
+	job.ID = response.Data.ID
+	job.Attempt = response.Data.Attempt
+	if prior := response.Data.PriorStatus; prior != nil {
+		switch *prior {
+		case db.VideoStatusREADY, db.VideoStatusPUBLIC, db.VideoStatusPRIVATE:
+			job.Republish = true
+		}
+	}
+	s.log.Info("Created transcoding job", "job_id", job.ID, "attempt", job.Attempt, "republish", job.Republish)
+	return nil
+}
+
+// Heartbeat tells the API the job is alive, so its video is not reaped
+// while a long stage sends no other update. It makes a single attempt and
+// carries no Idempotency-Key, since it changes nothing but updated_at.
+func (s *Service) Heartbeat(ctx context.Context, job *Job) error {
+	userID, videoID := job.UserAndVideoID()
+	return s.send(ctx, http.MethodPost, "/internal/media/videos/"+videoID+"/heartbeat", map[string]string{"user_id": userID}, "", nil)
+}
+
+// UpdateJob moves the job to status. A non-nil cause is stored as the
+// failure reason.
+func (s *Service) UpdateJob(ctx context.Context, job *Job, status db.JobStatus, cause error) error {
+	if job.ID == "" {
+		return nil
+	}
+	_, videoID := job.UserAndVideoID()
+
+	request := UpdateJobRequest{Status: status}
+	if cause != nil {
+		message := cause.Error()
+		request.ErrorMessage = &message
+	}
+	if status == db.JobStatusRUNNING {
+		request.Ladder = job.Ladder
+	}
+	return s.notifyDurable(ctx, http.MethodPatch, "/internal/media/videos/"+videoID+"/jobs/"+job.ID, request)
+}
+
+// notify sends payload to the API path with retries and decodes the
+// response into out when it is non-nil. All attempts share one
+// Idempotency-Key so the API applies the request at most once.
+func (s *Service) notify(ctx context.Context, method, path string, payload, out any) error {
+	key := uuid.NewString()
+	return retry(ctx, s.cfg.NotifierService.MaxAttempts, func() error {
+		return s.send(ctx, method, path, payload, key, out)
+	})
+}
+
+// notifyDurable is notify for state transitions that must not be lost. If
+// the API cannot be reached the request is written to the outbox, to be
+// replayed later, and nil is returned. Rejected requests are not queued.
+// Queued requests are replayed first; while any remain the request is
+// queued behind them instead of being sent, so transitions arrive in order.
+func (s *Service) notifyDurable(ctx context.Context, method, path string, payload any) error {
+	body, err := json.Marshal(payload)
 	if err != nil {
-		s.log.Error("failed to marshal update payload", "err", err)
 		return err
 	}
+	entry := outboxEntry{Key: uuid.NewString(), Method: method, Path: path, Payload: body}
 
-	req, err := http.NewRequestWithContext(ctx, "PATCH", url, bytes.NewReader(bodyBytes))
+	s.outbox.mu.Lock()
+	defer s.outbox.mu.Unlock()
+	if !s.replayOutbox(ctx) {
+		if err := s.outbox.Put(entry); err != nil {
+			return fmt.Errorf("queue behind outbox: %w", err)
+		}
+		s.log.Warn("Outbox not empty, queued request behind it", "method", method, "path", path)
+		return nil
+	}
+	err = retry(ctx, s.cfg.NotifierService.MaxAttempts, func() error {
+		return s.send(ctx, method, path, entry.Payload, entry.Key, nil)
+	})
+	if err == nil {
+		return nil
+	}
+	var rejected *rejectedError
+	if errors.As(err, &rejected) {
+		return err
+	}
+	if outboxErr := s.outbox.Put(entry); outboxErr != nil {
+		return fmt.Errorf("%w (outbox: %v)", err, outboxErr)
+	}
+	s.log.Warn("Notifier unreachable, queued request in outbox", "method", method, "path", path, "err", err)
+	return nil
+}
+
+// rejectedError is a 4xx answer that will not change on retry.
+type rejectedError struct {
+	status string
+}
+
+func (e *rejectedError) Error() string {
+	return "notifier rejected request: " + e.status
+}
+
+// send makes a single request to the API.
+func (s *Service) send(ctx context.Context, method, path string, payload any, key string, out any) error {
+	bodyBytes, ok := payload.(json.RawMessage)
+	if !ok {
+		var err error
+		if bodyBytes, err = json.Marshal(payload); err != nil {
+			s.log.Error("failed to marshal notifier payload", "err", err)
+			return permanent(err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.cfg.NotifierService.URL+path, bytes.NewReader(bodyBytes))
 	if err != nil {
 		s.log.Error("failed to build request", "err", err)
-		return err
+		return permanent(err)
 	}
 
-	username := s.cfg.NotifierService.Username
-	password := s.cfg.NotifierService.PASSWORD
 	req.Header.Set("Content-Type", "application/json")
-	if username != "" || password != "" {
-		req.SetBasicAuth(username, password)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	if err := s.signer.Sign(req, bodyBytes); err != nil {
+		return err
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := s.client.Do(req)
 	if err != nil {
-		s.log.Error("notifier request failed", "err", err)
+		s.log.Warn("notifier request failed", "err", err)
 		return err
 	}
 	defer res.Body.Close()
 
 	respBody, _ := io.ReadAll(res.Body)
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		s.log.Error("notifier returned non-2xx", "status", res.StatusCode, "body", string(respBody))
+		s.log.Warn("notifier returned non-2xx", "status", res.StatusCode, "body", string(respBody))
+		// Timeouts, rate limits, conflicts with an in-flight duplicate and
+		// server errors are worth another try; other 4xx are final.
+		switch res.StatusCode {
+		case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+			return fmt.Errorf("notifier returned status: %s", res.Status)
+		}
+		if res.StatusCode < 500 {
+			return permanent(&rejectedError{status: res.Status})
+		}
 		return fmt.Errorf("notifier returned status: %s", res.Status)
 	}
-
-	s.log.Info("Notifier updated metadata", "status", res.StatusCode)
+	if out != nil {
+		return permanent(json.Unmarshal(respBody, out))
+	}
 	return nil
 }
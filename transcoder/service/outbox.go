@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// outboxRetryDelay is the pause between replays while a worker waits for
+// its outbox to drain.
+const outboxRetryDelay = 10 * time.Second
+
+// outboxEntry is a notifier request that could not be delivered. The
+// Idempotency-Key is kept so a replay of a request the API did apply is
+// answered from its idempotency cache.
+type outboxEntry struct {
+	Key       string          `json:"key"`
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Outbox is a directory of undelivered notifier requests, one JSON file per
+// request, named so that lexical order is creation order.
+type Outbox struct {
+	dir string
+	// mu orders live callbacks with replays, so a live callback never
+	// overtakes a queued one.
+	mu sync.Mutex
+}
+
+func NewOutbox(dir string) *Outbox {
+	return &Outbox{dir: dir}
+}
+
+// Put durably stores entry. The file is written under a temporary name and
+// renamed, so a crash never leaves a partial entry behind.
+func (o *Outbox) Put(entry outboxEntry) error {
+	if err := os.MkdirAll(o.dir, 0o755); err != nil {
+		return err
+	}
+	entry.CreatedAt = time.Now().UTC()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(o.dir, ".pending-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%020d-%s.json", entry.CreatedAt.UnixNano(), entry.Key)
+	if err := os.Rename(tmp.Name(), filepath.Join(o.dir, name)); err != nil {
+		return err
+	}
+	// Persist the rename; not every platform can sync a directory.
+	if d, err := os.Open(o.dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// list returns the stored entry files, oldest first.
+func (o *Outbox) list() ([]string, error) {
+	entries, err := os.ReadDir(o.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// ReplayOutbox delivers queued notifier requests in order and reports
+// whether the outbox is empty afterwards. It stops at the first request the
+// API cannot take yet, so later transitions of a video never overtake
+// earlier ones. Requests the API rejects are dropped.
+func (s *Service) ReplayOutbox(ctx context.Context) bool {
+	s.outbox.mu.Lock()
+	defer s.outbox.mu.Unlock()
+	return s.replayOutbox(ctx)
+}
+
+// DrainOutbox replays the outbox until it is empty or ctx is done, and
+// reports whether it was emptied.
+func (s *Service) DrainOutbox(ctx context.Context) bool {
+	for !s.ReplayOutbox(ctx) {
+		sleep(ctx, outboxRetryDelay)
+		if ctx.Err() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// replayOutbox is ReplayOutbox with outbox.mu held.
+func (s *Service) replayOutbox(ctx context.Context) bool {
+	names, err := s.outbox.list()
+	if err != nil {
+		s.log.Error("Failed to read notifier outbox", "err", err)
+		return false
+	}
+	if len(names) == 0 {
+		return true
+	}
+	s.log.Info("Replaying notifier outbox", "entries", len(names))
+
+	for i, name := range names {
+		path := filepath.Join(s.outbox.dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.log.Error("Failed to read outbox entry", "file", name, "err", err)
+			return false
+		}
+		var entry outboxEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			s.log.Error("Dropping corrupt outbox entry", "file", name, "err", err)
+			os.Remove(path)
+			continue
+		}
+
+		err = s.send(ctx, entry.Method, entry.Path, entry.Payload, entry.Key, nil)
+		var rejected *rejectedError
+		switch {
+		case errors.As(err, &rejected):
+			s.log.Error("Dropping rejected outbox entry", "method", entry.Method, "path", entry.Path, "err", err)
+		case err != nil:
+			s.log.Warn("Notifier still unreachable, keeping outbox", "remaining", len(names)-i, "err", err)
+			return false
+		default:
+			s.log.Info("Replayed outbox entry", "method", entry.Method, "path", entry.Path, "queued_at", entry.CreatedAt)
+		}
+		if err := os.Remove(path); err != nil {
+			s.log.Error("Failed to remove outbox entry", "file", name, "err", err)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
+)
+
+// Packaging formats. Both share the same CMAF segments.
+const (
+	PackagingDASH = "DASH"
+	PackagingHLS  = "HLS"
+)
+
+// PackagingAttribute is the SQS message attribute that selects the
+// packaging of a job, e.g. "HLS" or "DASH,HLS".
+const PackagingAttribute = "packaging"
+
+// ParsePackaging parses a comma-separated list of packaging formats.
+func ParsePackaging(value string) ([]string, error) {
+	var formats []string
+	for _, format := range strings.Split(value, ",") {
+		format = strings.ToUpper(strings.TrimSpace(format))
+		switch format {
+		case "":
+			continue
+		case PackagingDASH, PackagingHLS:
+			if !slices.Contains(formats, format) {
+				formats = append(formats, format)
+			}
+		default:
+			return nil, fmt.Errorf("unknown packaging %q", format)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no packaging in %q", value)
+	}
+	return formats, nil
+}
+
+// Package finishes the manifests of the selected formats in the workspace
+// output and removes the ones the job did not ask for. HLS and DASH are
+// encrypted when Encryption.HLS and Encryption.DASH are set. Their schemes
+// differ, so each encrypted format gets its own copy of the segments; the
+// clear set stays shared by the formats published unencrypted, and is
+// dropped once no published manifest refers to it.
+func (s *Service) Package(ctx context.Context, job *Job, ws *Workspace) error {
+	outputDir := ws.Output
+	if err := ffmpeg.FixCodecs(outputDir); err != nil {
+		return fmt.Errorf("fix codecs: %w", err)
+	}
+	if job.Packages(PackagingHLS) {
+		if err := ffmpeg.WriteHLSMaster(outputDir); err != nil {
+			return fmt.Errorf("write hls master: %w", err)
+		}
+		if s.cfg.Encryption.HLS {
+			if err := s.EncryptHLS(ctx, job, outputDir); err != nil {
+				return fmt.Errorf("encrypt hls: %w", err)
+			}
+		}
+	}
+	if job.Packages(PackagingDASH) && s.cfg.Encryption.DASH {
+		if err := s.EncryptDASH(ctx, job, outputDir, ws.Packaging); err != nil {
+			return fmt.Errorf("encrypt dash: %w", err)
+		}
+	}
+
+	var stale []string
+	if !job.Packages(PackagingHLS) || job.EncryptedHLS {
+		stale = append(stale, "*.m3u8")
+	}
+	if !job.Packages(PackagingDASH) || job.EncryptedDASH {
+		stale = append(stale, ffmpeg.DashManifest)
+	}
+	if len(stale) == 2 {
+		stale = append(stale, "*.m4s")
+	}
+	return removeFiles(outputDir, stale...)
+}
+
+// removeFiles deletes the files in dir matching any of patterns.
+func removeFiles(dir string, patterns ...string) error {
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/config"
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
+)
+
+// Ladder methods, see Ladder.Method.
+const (
+	LadderFixed    = "fixed"
+	LadderPerTitle = "per_title"
+)
+
+type (
+	// Ladder records how the encoded ladder was chosen. It is stored on the
+	// transcoding job for auditing.
+	Ladder struct {
+		// Method is LadderFixed when the profile bitrates were used and
+		// LadderPerTitle when they were picked from sample encodes.
+		Method string `json:"method"`
+		// Fallback is why per-title analysis was enabled but not used.
+		Fallback string       `json:"fallback,omitempty"`
+		Rungs    []LadderRung `json:"rungs"`
+	}
+	LadderRung struct {
+		Name       string `json:"name"`
+		Codec      string `json:"codec"`
+		Resolution string `json:"resolution"`
+		// ConfiguredKbps is the bitrate of the fixed ladder, BitrateKbps
+		// the one encoded.
+		ConfiguredKbps int `json:"configured_kbps"`
+		BitrateKbps    int `json:"bitrate_kbps"`
+		// CRF and Trials are the per-title choice and the measurements it
+		// was made from, averaged over the samples.
+		CRF    *int          `json:"crf,omitempty"`
+		Trials []LadderTrial `json:"trials,omitempty"`
+	}
+	LadderTrial struct {
+		CRF         int     `json:"crf"`
+		BitrateKbps int     `json:"bitrate_kbps"`
+		PSNR        float64 `json:"psnr"`
+		SSIM        float64 `json:"ssim"`
+	}
+)
+
+// BuildLadder sets the encoded ladder of the job from its Rungs. With
+// PerTitle.Enabled the rung bitrates are picked from sample encodes in
+// samplesDir first; if that analysis fails the fixed ladder is used.
+func (s *Service) BuildLadder(ctx context.Context, job *Job, inputPath, samplesDir string) error {
+	fixed := ffmpeg.ExpandCodecs(job.Rungs, job.Source)
+	ladder := &Ladder{Method: LadderFixed}
+	rungs := job.Rungs
+
+	var results []LadderRung
+	if s.cfg.PerTitle.Enabled {
+		var err error
+		results, err = s.perTitle(ctx, job, inputPath, samplesDir)
+		switch {
+		case ctx.Err() != nil:
+			return fmt.Errorf("per-title analysis interrupted: %w", context.Cause(ctx))
+		case err != nil:
+			s.log.Warn("Per-title analysis failed, using the fixed ladder", "err", err)
+			ladder.Fallback = err.Error()
+			results = nil
+		default:
+			ladder.Method = LadderPerTitle
+			rungs = slices.Clone(job.Rungs)
+			for i := range rungs {
+				rungs[i].VideoBitrate = fmt.Sprintf("%dk", results[i].BitrateKbps)
+			}
+		}
+	}
+	job.Profiles = ffmpeg.ExpandCodecs(rungs, job.Source)
+
+	renditions := make([]string, 0, len(job.Profiles))
+	for i, p := range job.Profiles {
+		w, h := ffmpeg.RenditionSize(p, job.Source)
+		rung := LadderRung{
+			Name:           p.Name,
+			Codec:          codecName(p),
+			Resolution:     fmt.Sprintf("%dx%d", w, h),
+			ConfiguredKbps: fixed[i].VideoBitrateKbps(),
+			BitrateKbps:    p.VideoBitrateKbps(),
+		}
+		if results != nil {
+			if j := slices.IndexFunc(job.Rungs, func(r config.QualityProfile) bool {
+				return r.Name == p.Name && r.VideoCodec == p.VideoCodec
+			}); j >= 0 {
+				rung.CRF, rung.Trials = results[j].CRF, results[j].Trials
+			}
+		}
+		ladder.Rungs = append(ladder.Rungs, rung)
+		renditions = append(renditions, p.Name+"/"+p.VideoCodec+"@"+p.VideoBitrate)
+	}
+	job.Ladder = ladder
+	s.log.Info("Selected renditions", "method", ladder.Method, "renditions", strings.Join(renditions, ","), "source_kbps", job.Source.BitRateKbps())
+	return nil
+}
+
+// perTitle encodes PerTitle.Samples clips of the source at every CRF,
+// measures them against the source and picks, for each rung, the bitrate
+// of the highest CRF that still meets MinPSNR and MinSSIM. When no CRF
+// does, the lowest one is used. The bitrate is kept within the
+// configured factors of the rung bitrate and below the source bitrate.
+// The result is indexed like job.Rungs.
+func (s *Service) perTitle(ctx context.Context, job *Job, inputPath, dir string) ([]LadderRung, error) {
+	cfg := s.cfg.PerTitle
+	crfs := slices.Clone(cfg.CRFs)
+	slices.Sort(crfs)
+	crfs = slices.Compact(crfs)
+	if len(crfs) == 0 {
+		return nil, errors.New("no CRFs configured")
+	}
+	duration := job.Source.DurationSec()
+	if duration <= 0 {
+		return nil, ErrUnknownDuration
+	}
+
+	// Spread the samples over the source, each centred in an equal share
+	// of it. Short sources are sampled whole.
+	sampleSec := float64(max(cfg.SampleSec, 1))
+	count := max(cfg.Samples, 1)
+	offsets := []float64{0}
+	if duration > sampleSec*float64(count) {
+		offsets = offsets[:0]
+		for i := range count {
+			offsets = append(offsets, duration*float64(2*i+1)/float64(2*count)-sampleSec/2)
+		}
+	} else {
+		sampleSec = duration
+	}
+	s.log.Info("Running per-title analysis", "samples", len(offsets), "sample_sec", sampleSec, "crfs", crfs)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	rungs := job.Rungs
+	results := make([]LadderRung, len(rungs))
+	for _, crf := range crfs {
+		sums := make([]LadderTrial, len(rungs))
+		for k, offset := range offsets {
+			outputs := make([]string, len(rungs))
+			for i := range rungs {
+				outputs[i] = filepath.Join(dir, fmt.Sprintf("crf%d-sample%d-rung%d.mp4", crf, k, i))
+			}
+			if _, err := runFFmpeg(ctx, ffmpeg.SampleCommand(inputPath, offset, sampleSec, rungs, job.Source, crf, outputs)); err != nil {
+				return nil, fmt.Errorf("encode sample: %w", err)
+			}
+			for i, p := range rungs {
+				info, err := os.Stat(outputs[i])
+				if err != nil {
+					return nil, err
+				}
+				log, err := runFFmpeg(ctx, ffmpeg.QualityCommand(outputs[i], inputPath, offset, sampleSec, p, job.Source))
+				if err != nil {
+					return nil, fmt.Errorf("measure sample: %w", err)
+				}
+				psnr, ssim, err := ffmpeg.ParseQuality(log)
+				if err != nil {
+					return nil, fmt.Errorf("measure sample: %w", err)
+				}
+				sums[i].BitrateKbps += int(float64(info.Size()*8) / 1000 / sampleSec)
+				sums[i].PSNR += psnr
+				sums[i].SSIM += ssim
+			}
+		}
+		n := len(offsets)
+		for i := range rungs {
+			results[i].Trials = append(results[i].Trials, LadderTrial{
+				CRF:         crf,
+				BitrateKbps: sums[i].BitrateKbps / n,
+				PSNR:        sums[i].PSNR / float64(n),
+				SSIM:        sums[i].SSIM / float64(n),
+			})
+		}
+	}
+
+	sourceKbps := job.Source.BitRateKbps()
+	for i, p := range rungs {
+		chosen := results[i].Trials[0]
+		for _, trial := range results[i].Trials {
+			if trial.PSNR >= cfg.MinPSNR && trial.SSIM >= cfg.MinSSIM {
+				chosen = trial
+			}
+		}
+		configured := float64(p.VideoBitrateKbps())
+		kbps := max(chosen.BitrateKbps, int(configured*cfg.MinBitrateFactor))
+		kbps = min(kbps, int(configured*cfg.MaxBitrateFactor))
+		if sourceKbps > 0 {
+			kbps = min(kbps, sourceKbps)
+		}
+		results[i].CRF = &chosen.CRF
+		results[i].BitrateKbps = max(kbps, 1)
+	}
+	return results, nil
+}
+
+// runFFmpeg runs an analysis command and returns its log.
+func runFFmpeg(ctx context.Context, args []string) (string, error) {
+	cmd := ffmpeg.Command(ctx, args)
+	var output bytes.Buffer
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("ffmpeg interrupted: %w", context.Cause(ctx))
+		}
+		return "", fmt.Errorf("ffmpeg %w: %s", err, tail(output.String(), ffmpegErrorLines))
+	}
+	return output.String(), nil
+}
+
+// codecName is the codec family of p as reported to the API, or its
+// encoder when the family is unknown.
+func codecName(p config.QualityProfile) string {
+	if family := config.CodecFamily(p.VideoCodec); family != "" {
+		return family
+	}
+	return p.VideoCodec
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const pollRetryDelay = 5 * time.Second
+
+// Poll long-polls the upload queue and processes jobs one after another
+// until ctx is cancelled.
+func (s *Service) Poll(ctx context.Context) {
+	s.log.Info("Polling queue for jobs", "queue", s.cfg.Queue.URL)
+	for ctx.Err() == nil {
+		s.ReplayOutbox(ctx)
+		messages, err := s.queue.GetMessages(ctx, s.cfg.Queue.URL, 1, s.cfg.Queue.WaitTimeSec)
+		if err != nil {
+			s.log.Error("Failed to receive messages", "err", err)
+			sleep(ctx, pollRetryDelay)
+			continue
+		}
+		for _, message := range messages {
+			s.handleMessage(ctx, message)
+		}
+	}
+	s.log.Info("Stopped polling queue")
+}
+
+func (s *Service) handleMessage(ctx context.Context, message types.Message) {
+	receiptHandle := aws.ToString(message.ReceiptHandle)
+	job, err := NewJob(aws.ToString(message.Body))
+	if err != nil {
+		// Malformed bodies and s3:TestEvent notifications can never succeed,
+		// so drop them instead of letting them bounce around the queue.
+		if !errors.Is(err, ErrEmptyEvent) {
+			s.log.Error("Discarding malformed message", "message_id", aws.ToString(message.MessageId), "err", err)
+		}
+		s.queue.DeleteMessage(ctx, s.cfg.Queue.URL, receiptHandle)
+		return
+	}
+	job.ReceiptHandle = receiptHandle
+	if attr, ok := message.MessageAttributes[PackagingAttribute]; ok {
+		packaging, err := ParsePackaging(aws.ToString(attr.StringValue))
+		if err != nil {
+			s.log.Error("Discarding message with invalid packaging", "message_id", aws.ToString(message.MessageId), "err", err)
+			s.queue.DeleteMessage(ctx, s.cfg.Queue.URL, receiptHandle)
+			return
+		}
+		job.Packaging = packaging
+	}
+
+	stop := s.keepMessageInvisible(ctx, receiptHandle)
+	err = s.Process(ctx, job)
+	var rejected *RejectedError
+	if (err == nil || errors.As(err, &rejected)) && !s.DrainOutbox(ctx) {
+		// The final status of the video is only in the outbox, which may
+		// not outlive this worker. The message is kept until it is
+		// delivered, so a lost worker means a redelivery rather than a
+		// video stuck in its last reported state.
+		err, rejected = errors.New("final status not delivered to the API"), nil
+	}
+	stop()
+	if rejected != nil {
+		// A rejected source fails the same way on every delivery.
+		s.log.Warn("Rejected video", "key", job.Key(), "reason", rejected.Reason, "err", err)
+		s.queue.DeleteMessage(ctx, s.cfg.Queue.URL, receiptHandle)
+		return
+	}
+	if err != nil {
+		// The message is left on the queue so SQS redelivers it once the
+		// visibility timeout expires. On shutdown it is released right away
+		// so another worker can take over.
+		s.log.Error("Error processing video", "key", job.Key(), "error", err)
+		if ctx.Err() != nil {
+			s.queue.ChangeMessageVisibility(context.WithoutCancel(ctx), s.cfg.Queue.URL, receiptHandle, 0)
+		}
+		return
+	}
+
+	if err := s.queue.DeleteMessage(ctx, s.cfg.Queue.URL, receiptHandle); err != nil {
+		s.log.Error("Failed to delete processed message", "key", job.Key(), "err", err)
+		return
+	}
+	s.log.Info("Video processing completed successfully", "key", job.Key())
+}
+
+// keepMessageInvisible periodically extends the visibility timeout of the
+// message while it is being processed. The returned func stops it.
+func (s *Service) keepMessageInvisible(ctx context.Context, receiptHandle string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	timeout := s.cfg.Queue.VisibilityTimeoutSec
+	interval := time.Duration(timeout) * time.Second / 2
+	if interval <= 0 {
+		return cancel
+	}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.log.Debug("Extending message visibility", "timeout_sec", timeout)
+				s.queue.ChangeMessageVisibility(ctx, s.cfg.Queue.URL, receiptHandle, timeout)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// deleteBatchSize is the most keys a single DeleteObjects call accepts.
+const deleteBatchSize = 1000
+
+// PruneOutputs deletes every object under the video's output root that job
+// did not store: the outputs of earlier attempts and whatever failed
+// republishes left behind. It must only run once the API took the job's
+// output report and ready update, so nothing it deletes is still served.
+// Earlier thumbnails are kept when job produced none, as the video still
+// lists them.
+func (s *Service) PruneOutputs(ctx context.Context, job *Job) error {
+	keep := make(map[string]bool, len(job.OutputKeys)+len(job.ThumbnailKeys))
+	for _, key := range job.OutputKeys {
+		keep[key] = true
+	}
+	for _, key := range job.ThumbnailKeys {
+		keep[key] = true
+	}
+	keepThumbnails := len(job.ThumbnailKeys) == 0
+
+	return s.deletePrefix(ctx, job.OutputRoot(), func(key string) bool {
+		return !keep[key] && !(keepThumbnails && strings.Contains(key, "/thumbnails/"))
+	})
+}
+
+// RemoveOutputs deletes the job's output prefix. It is for a republish that
+// failed before its outputs were reported, whose uploads nothing refers to.
+func (s *Service) RemoveOutputs(ctx context.Context, job *Job) error {
+	return s.deletePrefix(ctx, job.OutputPrefix(), func(string) bool { return true })
+}
+
+// deletePrefix deletes the objects under prefix that match stale.
+func (s *Service) deletePrefix(ctx context.Context, prefix string, stale func(key string) bool) error {
+	client := s.storage.Client()
+	var batch []types.ObjectIdentifier
+	deleted := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := retry(ctx, s.cfg.Transfer.MaxAttempts, func() error {
+			out, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(s.cfg.Aws.MediaBucket),
+				Delete: &types.Delete{Objects: batch, Quiet: aws.Bool(true)},
+			})
+			if err == nil && len(out.Errors) > 0 {
+				err = fmt.Errorf("%d objects not deleted, first %s: %s", len(out.Errors), aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("delete objects: %w", err)
+		}
+		deleted += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.Aws.MediaBucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("list %s: %w", prefix, err)
+		}
+		for _, object := range page.Contents {
+			if !stale(aws.ToString(object.Key)) {
+				continue
+			}
+			batch = append(batch, types.ObjectIdentifier{Key: object.Key})
+			if len(batch) == deleteBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if deleted > 0 {
+		s.log.Info("Deleted stale outputs", "prefix", prefix, "objects", deleted)
+	}
+	return nil
+}
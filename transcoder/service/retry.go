@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// permanentError wraps an error that retry must not retry.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent marks err as final so retry returns it right away. It returns
+// nil for a nil err.
+func permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// retry calls fn until it succeeds, ctx is done or attempts calls have
+// failed, sleeping with jittered exponential backoff in between. Errors
+// marked with permanent end it early. It returns the last error of fn.
+func retry(ctx context.Context, attempts int, fn func() error) error {
+	var err error
+	for attempt := range max(attempts, 1) {
+		if attempt > 0 {
+			sleep(ctx, backoff(attempt))
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if err == nil {
+				err = ctxErr
+			}
+			return err
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+	}
+	return err
+}
+
+// backoff returns the delay before retry number attempt (starting at 1),
+// picked uniformly from [d/2, d) with d doubling per attempt.
+func backoff(attempt int) time.Duration {
+	d := min(retryBaseDelay<<min(attempt-1, 16), retryMaxDelay)
+	return d/2 + rand.N(d/2)
+}
@@ -1,7 +1,12 @@
 package service
 
 import (
+	"net/http"
+	"time"
+
 	"gitlab.com/subrotokumar/playstack/libs/core"
+	"gitlab.com/subrotokumar/playstack/libs/hmacauth"
+	"gitlab.com/subrotokumar/playstack/libs/queue"
 	"gitlab.com/subrotokumar/playstack/libs/storage"
 	"gitlab.com/subrotokumar/playstack/transcoder/config"
 )
@@ -10,8 +15,17 @@ type Service struct {
 	cfg     config.Config
 	log     *core.Logger
 	storage *storage.Storage
-	bucket  string
-	path    string
+	queue   *queue.Queue
+	// client and outbox deliver callbacks to the API.
+	client *http.Client
+	signer *hmacauth.Signer
+	outbox *Outbox
+	// profiles is the quality ladder every job is encoded with.
+	profiles []config.QualityProfile
+	// packaging is the default Transcode.Packaging of jobs.
+	packaging []string
+	bucket    string
+	path      string
 }
 
 func New() *Service {
@@ -20,15 +34,40 @@ func New() *Service {
 		panic(err)
 	}
 	log := core.NewLogger(cfg.App.Env, cfg.App.Name, cfg.Log.Level)
-	s3Event, err := cfg.ParseS3Event()
+	if cfg.Event == "" && cfg.Queue.URL == "" {
+		log.Fatal("either SQS_MESSAGE or SQS_QUEUE_URL must be set")
+	}
+	if (cfg.Encryption.HLS || cfg.Encryption.DASH) && cfg.Encryption.KeyBaseURL == "" {
+		log.Fatal("ENCRYPTION_KEY_BASE_URL must be set when ENCRYPTION_HLS or ENCRYPTION_DASH is enabled")
+	}
+	if cfg.Encryption.HLS && cfg.Encryption.DASH {
+		log.Warn("ENCRYPTION_HLS and ENCRYPTION_DASH are both enabled, jobs publishing both formats store and upload every segment twice")
+	}
+	profiles, err := config.LoadProfiles(cfg.Transcode.ProfilesFile)
 	if err != nil {
-		log.Fatal("failed to unmarshell SQS_MESSAGE")
+		log.Fatal("failed to load quality profiles", "path", cfg.Transcode.ProfilesFile, "err", err)
+	}
+	packaging, err := ParsePackaging(cfg.Transcode.Packaging)
+	if err != nil {
+		log.Fatal("invalid TRANSCODE_PACKAGING", "err", err)
+	}
+	signer, err := hmacauth.NewSigner(cfg.NotifierService.HMACKeyID, cfg.NotifierService.HMACSecret)
+	if err != nil {
+		log.Fatal("failed to create callback signer", "err", err)
 	}
-	cfg.S3Event = s3Event
 	storage := storage.NewStorageProvider(cfg.Aws.Region)
-	return &Service{
-		cfg:     cfg,
-		log:     log,
-		storage: storage,
+	svc := &Service{
+		cfg:       cfg,
+		log:       log,
+		storage:   storage,
+		profiles:  profiles,
+		packaging: packaging,
+		client:    &http.Client{Timeout: time.Duration(cfg.NotifierService.TimeoutSec) * time.Second},
+		signer:    signer,
+		outbox:    NewOutbox(cfg.NotifierService.OutboxDir),
+	}
+	if cfg.Event == "" {
+		svc.queue = queue.NewMessageQueue(cfg.Aws.Region, log)
 	}
+	return svc
 }
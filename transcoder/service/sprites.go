@@ -0,0 +1,62 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
+)
+
+// spriteDir is where trick-play sheets and their track live, relative to
+// the job output.
+const spriteDir = "sprites"
+
+// Sprites renders trick-play sprite sheets and the matching WebVTT track into
+// spritePath. It runs before Upload so the files ship with the DASH output.
+func (s *Service) Sprites(ctx context.Context, job *Job, inputPath, spritePath string) error {
+	cfg := s.cfg.Transcode
+	if cfg.SpriteIntervalSec <= 0 || cfg.SpriteColumns <= 0 || cfg.SpriteRows <= 0 || cfg.SpriteWidth <= 0 {
+		return nil
+	}
+	duration := job.Source.DurationSec()
+	if duration <= 0 {
+		return ErrUnknownDuration
+	}
+	if err := os.MkdirAll(spritePath, 0o755); err != nil {
+		return err
+	}
+
+	layout := ffmpeg.NewSpriteLayout(cfg.SpriteIntervalSec, cfg.SpriteColumns, cfg.SpriteRows, cfg.SpriteWidth, job.Source)
+	s.log.Info("Generating trick-play sprites", "interval_sec", layout.IntervalSec, "tile", fmt.Sprintf("%dx%d", layout.TileWidth, layout.TileHeight))
+
+	cmdArgs := ffmpeg.SpriteCommand(inputPath, spritePath, layout)
+	cmd := ffmpeg.Command(ctx, cmdArgs)
+	var output bytes.Buffer
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		// Leave nothing half-written behind for Upload to pick up.
+		os.RemoveAll(spritePath)
+		return fmt.Errorf("ffmpeg %w: %s", err, tail(output.String(), ffmpegErrorLines))
+	}
+
+	track, err := os.Create(filepath.Join(spritePath, ffmpeg.SpriteTrack))
+	if err != nil {
+		os.RemoveAll(spritePath)
+		return err
+	}
+	if err := ffmpeg.WriteSpriteTrack(track, layout, duration); err != nil {
+		track.Close()
+		os.RemoveAll(spritePath)
+		return err
+	}
+	if err := track.Close(); err != nil {
+		os.RemoveAll(spritePath)
+		return err
+	}
+
+	job.SpriteTrack = true
+	return nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
+)
+
+const (
+	// Frames whose mean luma falls outside this range are treated as
+	// black or white frames (fades, slates) and never become the poster.
+	minPosterLuma = 16
+	maxPosterLuma = 240
+	// lumaSampleStep skips pixels when scoring; candidates are small
+	// enough that every fourth pixel in each direction is representative.
+	lumaSampleStep = 4
+)
+
+var (
+	ErrNoThumbnails    = errors.New("no thumbnail candidates could be extracted")
+	ErrUnknownDuration = errors.New("unknown source duration")
+)
+
+// Thumbnails extracts poster candidates at evenly spaced points of the
+// source, uploads them next to the outputs and picks the most detailed
+// frame as the default poster. The result is stored on the job.
+func (s *Service) Thumbnails(ctx context.Context, job *Job, inputPath, thumbnailDir string) error {
+	count := s.cfg.Transcode.ThumbnailCount
+	if count <= 0 {
+		return nil
+	}
+	duration := job.Source.DurationSec()
+	if duration <= 0 {
+		return ErrUnknownDuration
+	}
+	if err := os.MkdirAll(thumbnailDir, 0o755); err != nil {
+		return err
+	}
+
+	bestScore := -1.0
+	for i := range count {
+		// Spread candidates over the middle of the video, skipping the
+		// very first and last frames which are usually fades.
+		at := duration * float64(i+1) / float64(count+1)
+		name := fmt.Sprintf("thumb_%d.jpg", i+1)
+		path := filepath.Join(thumbnailDir, name)
+
+		if err := ffmpeg.GenerateThumbnail(ctx, inputPath, path, strconv.FormatFloat(at, 'f', 3, 64)); err != nil {
+			s.log.Warn("Failed to extract thumbnail", "at_sec", at, "err", err)
+			continue
+		}
+		score, err := scoreThumbnail(path)
+		if err != nil {
+			s.log.Warn("Failed to score thumbnail", "file", name, "err", err)
+			continue
+		}
+
+		key := job.OutputPrefix() + "thumbnails/" + name
+		if err := s.uploadThumbnail(ctx, path, key); err != nil {
+			return err
+		}
+		job.ThumbnailKeys = append(job.ThumbnailKeys, key)
+		if score > bestScore {
+			bestScore = score
+			job.PosterKey = key
+		}
+		s.log.Debug("Thumbnail candidate", "key", key, "score", score)
+	}
+
+	if len(job.ThumbnailKeys) == 0 {
+		return ErrNoThumbnails
+	}
+	s.log.Info("Selected poster", "key", job.PosterKey, "candidates", len(job.ThumbnailKeys))
+	return nil
+}
+
+func (s *Service) uploadThumbnail(ctx context.Context, path, key string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := s.uploadFile(ctx, uploadFile{path: path, key: key, size: info.Size()}); err != nil {
+		return fmt.Errorf("upload thumbnail %s: %w", key, err)
+	}
+	return nil
+}
+
+// scoreThumbnail rates how useful a frame is as a poster. Near-black and
+// near-white frames score zero; otherwise the luma standard deviation is
+// used so that detailed, well-exposed frames win over flat ones.
+func scoreThumbnail(path string) (float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	img, err := jpeg.Decode(file)
+	if err != nil {
+		return 0, err
+	}
+	mean, stddev := lumaStats(img)
+	if mean < minPosterLuma || mean > maxPosterLuma {
+		return 0, nil
+	}
+	return stddev, nil
+}
+
+// lumaStats returns the mean and standard deviation of the 8-bit luma of img.
+func lumaStats(img image.Image) (mean, stddev float64) {
+	bounds := img.Bounds()
+	var sum, sumSq, n float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += lumaSampleStep {
+		for x := bounds.Min.X; x < bounds.Max.X; x += lumaSampleStep {
+			var l float64
+			if ycc, ok := img.(*image.YCbCr); ok {
+				l = float64(ycc.Y[ycc.YOffset(x, y)])
+			} else {
+				r, g, b, _ := img.At(x, y).RGBA()
+				l = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+			}
+			sum += l
+			sumSq += l * l
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	mean = sum / n
+	return mean, math.Sqrt(math.Max(sumSq/n-mean*mean, 0))
+}
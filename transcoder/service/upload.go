@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
+	"golang.org/x/sync/errgroup"
+)
+
+const mb = 1 << 20
+
+// manifestExts are uploaded after every other file so a manifest never
+// references a segment that has not landed yet. HLS master playlists go
+// last of all, after the media playlists they list.
+var manifestExts = []string{".mpd", ".m3u8"}
+
+// uploadFile is a file of the output dir and the key it is stored under.
+type uploadFile struct {
+	path string
+	key  string
+	size int64
+}
+
+// Upload copies the output dir to the job output prefix. Segments and other
+// media are uploaded in parallel, bounded by Transfer.Concurrency; manifests
+// follow once all of them have succeeded, and master playlists once the
+// other manifests have.
+func (s *Service) Upload(ctx context.Context, job *Job, sourceDir string) error {
+	s.log.Info("Uploading files from", "dir", sourceDir)
+	uploadKey := job.OutputPrefix()
+
+	var media, manifests, masters []uploadFile
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		file := uploadFile{path: path, key: uploadKey + filepath.ToSlash(relPath), size: info.Size()}
+		switch {
+		case d.Name() == ffmpeg.HLSMaster:
+			masters = append(masters, file)
+		case slices.Contains(manifestExts, strings.ToLower(filepath.Ext(path))):
+			manifests = append(manifests, file)
+		default:
+			media = append(media, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walk source dir: %w", err)
+	}
+
+	if err := s.uploadAll(ctx, media); err != nil {
+		return err
+	}
+	s.log.Info("Uploaded media files, publishing manifests", "files", len(media), "manifests", len(manifests)+len(masters))
+	if err := s.uploadAll(ctx, manifests); err != nil {
+		return err
+	}
+	if err := s.uploadAll(ctx, masters); err != nil {
+		return err
+	}
+	for _, file := range slices.Concat(media, manifests, masters) {
+		job.OutputKeys = append(job.OutputKeys, file.key)
+	}
+	return nil
+}
+
+// uploadAll uploads files with at most Transfer.Concurrency in flight and
+// stops at the first failure.
+func (s *Service) uploadAll(ctx context.Context, files []uploadFile) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(s.cfg.Transfer.Concurrency, 1))
+	for _, file := range files {
+		g.Go(func() error {
+			if err := s.uploadFile(ctx, file); err != nil {
+				return fmt.Errorf("upload file %s: %w", file.key, err)
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+func (s *Service) uploadFile(ctx context.Context, file uploadFile) error {
+	f, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if file.size >= s.cfg.Transfer.MultipartThresholdMB*mb {
+		return s.uploadMultipart(ctx, f, file)
+	}
+
+	s.log.Debug("Uploading", "key", file.key)
+	return retry(ctx, s.cfg.Transfer.MaxAttempts, func() error {
+		_, err := s.storage.Client().PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(s.cfg.Aws.MediaBucket),
+			Key:           aws.String(file.key),
+			Body:          io.NewSectionReader(f, 0, file.size),
+			ContentLength: aws.Int64(file.size),
+			ContentType:   aws.String(getContentType(file.key)),
+		})
+		return err
+	})
+}
+
+// uploadMultipart uploads f in Transfer.PartSizeMB parts, retrying each part
+// on its own. A failed upload is aborted so S3 does not keep the parts.
+func (s *Service) uploadMultipart(ctx context.Context, f *os.File, file uploadFile) error {
+	client := s.storage.Client()
+	partSize := max(s.cfg.Transfer.PartSizeMB, 5) * mb
+	s.log.Info("Uploading in parts", "key", file.key, "size", file.size, "part_size", partSize)
+
+	var upload *s3.CreateMultipartUploadOutput
+	err := retry(ctx, s.cfg.Transfer.MaxAttempts, func() (err error) {
+		upload, err = client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket:      aws.String(s.cfg.Aws.MediaBucket),
+			Key:         aws.String(file.key),
+			ContentType: aws.String(getContentType(file.key)),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("create multipart upload: %w", err)
+	}
+
+	parts, err := s.uploadParts(ctx, f, file, upload.UploadId, partSize)
+	if err == nil {
+		err = retry(ctx, s.cfg.Transfer.MaxAttempts, func() error {
+			_, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+				Bucket:          aws.String(s.cfg.Aws.MediaBucket),
+				Key:             aws.String(file.key),
+				UploadId:        upload.UploadId,
+				MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+			})
+			return err
+		})
+	}
+	if err != nil {
+		_, abortErr := client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.cfg.Aws.MediaBucket),
+			Key:      aws.String(file.key),
+			UploadId: upload.UploadId,
+		})
+		if abortErr != nil {
+			s.log.Warn("Failed to abort multipart upload", "key", file.key, "err", abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Service) uploadParts(ctx context.Context, f *os.File, file uploadFile, uploadID *string, partSize int64) ([]types.CompletedPart, error) {
+	count := int((file.size + partSize - 1) / partSize)
+	parts := make([]types.CompletedPart, count)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(s.cfg.Transfer.Concurrency, 1))
+	for i := range count {
+		g.Go(func() error {
+			offset := int64(i) * partSize
+			size := min(partSize, file.size-offset)
+			partNumber := aws.Int32(int32(i + 1))
+			return retry(ctx, s.cfg.Transfer.MaxAttempts, func() error {
+				out, err := s.storage.Client().UploadPart(ctx, &s3.UploadPartInput{
+					Bucket:        aws.String(s.cfg.Aws.MediaBucket),
+					Key:           aws.String(file.key),
+					UploadId:      uploadID,
+					PartNumber:    partNumber,
+					Body:          io.NewSectionReader(f, offset, size),
+					ContentLength: aws.Int64(size),
+				})
+				if err != nil {
+					s.log.Warn("Part upload failed", "key", file.key, "part", i+1, "err", err)
+					return err
+				}
+				parts[i] = types.CompletedPart{ETag: out.ETag, PartNumber: partNumber}
+				return nil
+			})
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("upload parts: %w", err)
+	}
+	return parts, nil
+}
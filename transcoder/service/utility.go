@@ -1,10 +1,18 @@
 package service
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/config"
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
 )
 
+// resolutionLabels are the video_resolution values known to the API,
+// highest first.
+var resolutionLabels = []int{2160, 1440, 1080, 720, 480, 360, 240}
+
 func getContentType(filePath string) string {
 	ext := strings.ToLower(filepath.Ext(filePath))
 
@@ -17,7 +25,77 @@ func getContentType(filePath string) string {
 		return "application/dash+xml"
 	case ".m4s":
 		return "video/mp4"
+	case ".vtt":
+		return "text/vtt"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
 	default:
 		return "application/octet-stream"
 	}
 }
+
+// jobOutputs describes the renditions and manifests written by CmafCommand
+// for the job, keyed the way Upload stores them.
+func jobOutputs(job *Job) UpdateOutputsRequest {
+	prefix := job.OutputPrefix()
+	var request UpdateOutputsRequest
+	// Renditions point at the init segments of the DASH output, or of the
+	// HLS output when DASH is not published.
+	segments := prefix
+	if job.Packages(PackagingDASH) {
+		dir := ""
+		if job.EncryptedDASH {
+			dir = dashDir + "/"
+		}
+		request.Manifests = append(request.Manifests, Manifest{Type: PackagingDASH, S3Key: prefix + dir + ffmpeg.DashManifest})
+		segments = prefix + dir
+	}
+	if job.Packages(PackagingHLS) {
+		dir := ""
+		if job.EncryptedHLS {
+			dir = hlsDir + "/"
+		}
+		request.Manifests = append(request.Manifests, Manifest{Type: PackagingHLS, S3Key: prefix + dir + ffmpeg.HLSMaster})
+		if !job.Packages(PackagingDASH) {
+			segments = prefix + dir
+		}
+	}
+	if job.SpriteTrack {
+		request.Manifests = append(request.Manifests, Manifest{
+			Type:  "THUMBNAILS",
+			S3Key: prefix + spriteDir + "/" + ffmpeg.SpriteTrack,
+		})
+	}
+	for i, p := range job.Profiles {
+		w, h := ffmpeg.RenditionSize(p, job.Source)
+		request.Renditions = append(request.Renditions, Rendition{
+			Resolution:  resolutionLabel(w, h),
+			BitrateKbps: int32(p.VideoBitrateKbps()),
+			S3Key:       fmt.Sprintf("%sinit-stream%d.m4s", segments, i),
+			Codec:       config.CodecFamily(p.VideoCodec),
+		})
+	}
+	request.KeyIDs = job.KeyIDs
+	return request
+}
+
+// resolutionLabel maps a frame size to the closest video_resolution label
+// that does not exceed its short side.
+func resolutionLabel(width, height int) string {
+	short := min(width, height)
+	for _, label := range resolutionLabels {
+		if short >= label {
+			return fmt.Sprintf("%dp", label)
+		}
+	}
+	return fmt.Sprintf("%dp", resolutionLabels[len(resolutionLabels)-1])
+}
+
+// tail returns the last n non-empty lines of output.
+func tail(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
+)
+
+// RejectReason is the machine-readable code stored with a rejected video.
+type RejectReason string
+
+const (
+	ReasonUnsupportedContainer RejectReason = "UNSUPPORTED_CONTAINER"
+	ReasonUnreadableFile       RejectReason = "UNREADABLE_FILE"
+	ReasonNoVideoStream        RejectReason = "NO_VIDEO_STREAM"
+	ReasonUnsupportedCodec     RejectReason = "UNSUPPORTED_CODEC"
+	ReasonZeroDuration         RejectReason = "ZERO_DURATION"
+	ReasonTooLong              RejectReason = "TOO_LONG"
+	ReasonResolutionTooHigh    RejectReason = "RESOLUTION_TOO_HIGH"
+)
+
+// RejectedError reports a source that can never be transcoded. Its message
+// is deleted instead of retried.
+type RejectedError struct {
+	Reason RejectReason
+	Detail string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("rejected %s: %s", e.Reason, e.Detail)
+}
+
+func reject(reason RejectReason, format string, args ...any) error {
+	return &RejectedError{Reason: reason, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Validate checks a probed source against the Validation limits.
+func (s *Service) Validate(info *ffmpeg.VideoInfo) error {
+	cfg := s.cfg.Validation
+	stream := info.VideoStream()
+	if stream == nil {
+		return reject(ReasonNoVideoStream, "source %q has no video stream", info.Format.FormatName)
+	}
+	if !slices.Contains(cfg.VideoCodecs, stream.CodecName) {
+		return reject(ReasonUnsupportedCodec, "video codec %q is not one of %s", stream.CodecName, strings.Join(cfg.VideoCodecs, ", "))
+	}
+
+	duration := info.DurationSec()
+	if duration <= 0 {
+		return reject(ReasonZeroDuration, "source has no duration")
+	}
+	if cfg.MaxDurationSec > 0 && duration > float64(cfg.MaxDurationSec) {
+		return reject(ReasonTooLong, "duration %.0fs exceeds %ds", duration, cfg.MaxDurationSec)
+	}
+
+	width, height := stream.DisplaySize()
+	maxWidth, maxHeight := cfg.MaxWidth, cfg.MaxHeight
+	if height > width {
+		maxWidth, maxHeight = maxHeight, maxWidth
+	}
+	if (maxWidth > 0 && width > maxWidth) || (maxHeight > 0 && height > maxHeight) {
+		return reject(ReasonResolutionTooHigh, "resolution %dx%d exceeds %dx%d", width, height, maxWidth, maxHeight)
+	}
+	return nil
+}
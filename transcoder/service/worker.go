@@ -1,64 +1,80 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"os"
+	"math"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"gitlab.com/subrotokumar/playstack/libs/db"
 	"gitlab.com/subrotokumar/playstack/transcoder/ffmpeg"
 )
 
 const (
 	MsgVideoMetadataUpdateFailed string = "failed to update video metadata"
+	MsgJobUpdateFailed           string = "failed to update transcoding job"
 )
 
-func (s *Service) Download(ctx context.Context, destPath string) error {
-	s.log.Info("Downloading file", "path", destPath)
+const (
+	// ffmpegErrorLines is how much of the ffmpeg log is kept as a failure reason.
+	ffmpegErrorLines = 5
+	// finalUpdateTimeout bounds the status updates sent after a job was
+	// interrupted.
+	finalUpdateTimeout = 30 * time.Second
+)
 
-	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
-		return fmt.Errorf("create dir: %w", err)
+func (s *Service) Analyze(ctx context.Context, job *Job, inputPath string) error {
+	info, err := ffmpeg.AnalyzeVideo(ctx, inputPath)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return reject(ReasonUnreadableFile, "ffprobe cannot read source: %v", err)
 	}
-
-	out, err := s.storage.Client().GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.cfg.Bucket()),
-		Key:    aws.String(s.cfg.Key()),
-	})
 	if err != nil {
-		return fmt.Errorf("get object failed: %w", err)
+		return err
 	}
-	defer out.Body.Close()
-
-	file, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("create file: %w", err)
+	if err := s.Validate(info); err != nil {
+		return err
 	}
-	defer file.Close()
-
-	_, err = io.Copy(file, out.Body)
-	if err != nil {
-		return fmt.Errorf("write file: %w", err)
+	job.Source = info
+	job.Rungs = ffmpeg.SelectProfiles(s.profiles, info)
+	if len(job.Rungs) == 0 {
+		return errors.New("no usable quality profile for source")
 	}
-
 	return nil
 }
 
-func (s *Service) Transcode(ctx context.Context, inputPath, outputDir string) error {
+func (s *Service) Transcode(ctx context.Context, job *Job, inputPath, outputDir string) error {
 	s.log.Info("Transcoding media", "input", inputPath, "output", outputDir)
 
-	cmdArgs := ffmpeg.DashCommand(inputPath, outputDir)
-	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
-	output, err := cmd.CombinedOutput()
+	cmdArgs := ffmpeg.WithProgress(ffmpeg.CmafCommand(inputPath, outputDir, job.Profiles, job.Source))
+	cmd := ffmpeg.Command(ctx, cmdArgs)
+	var output bytes.Buffer
+	cmd.Stderr = &output
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return err
 	}
-	for _, line := range strings.Split(string(output), "\n") {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	report, stopReports := s.progressReporter(ctx, job)
+	if err := ffmpeg.ParseProgress(stdout, report); err != nil {
+		s.log.Warn("Failed to read ffmpeg progress", "err", err)
+	}
+	stopReports()
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("ffmpeg interrupted: %w", context.Cause(ctx))
+		}
+		return fmt.Errorf("ffmpeg %w: %s", err, tail(output.String(), ffmpegErrorLines))
+	}
+	for _, line := range strings.Split(output.String(), "\n") {
 		if line == "" {
 			continue
 		}
@@ -67,102 +83,278 @@ func (s *Service) Transcode(ctx context.Context, inputPath, outputDir string) er
 	return nil
 }
 
-func (s *Service) Upload(ctx context.Context, sourceDir string) error {
-	s.log.Info("Uploading files from", "dir", sourceDir)
-	uploadKey := strings.ReplaceAll(s.cfg.Key(), "video.mp4", "output/")
-	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
+// progressReporter returns a ParseProgress callback that pushes the encode
+// percentage to the API at most once per ProgressIntervalSec, and a stop
+// function that waits for the last report. Reports are sent from their own
+// goroutine through a one-slot channel holding only the latest percentage,
+// so a slow API never stalls the reader of ffmpeg's output.
+func (s *Service) progressReporter(ctx context.Context, job *Job) (func(time.Duration, bool), func()) {
+	interval := time.Duration(s.cfg.Transcode.ProgressIntervalSec) * time.Second
+	duration := job.Source.DurationSec()
+	lastPercent := -1
+	var lastSent time.Time
 
-		relPath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return err
+	updates := make(chan int16, 1)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for percent := range updates {
+			if err := s.ReportProgress(ctx, job, percent); err != nil {
+				s.log.Warn("Failed to report progress", "err", err)
+			}
 		}
+	}()
 
-		file, err := os.Open(path)
-		if err != nil {
-			return err
+	report := func(outTime time.Duration, done bool) {
+		percent := ffmpeg.Percent(outTime, duration)
+		if done {
+			percent = 100
 		}
-		defer file.Close()
-
-		s.log.Info("Uploading", "key", uploadKey+relPath)
-		_, err = s.storage.Client().PutObject(ctx, &s3.PutObjectInput{
-			Bucket:      aws.String(s.cfg.Aws.MediaBucket),
-			Key:         aws.String(uploadKey + relPath),
-			Body:        file,
-			ContentType: aws.String(getContentType(relPath)),
-		})
-		if err != nil {
-			return fmt.Errorf("upload file %s: %w", relPath, err)
+		if percent == lastPercent || (!done && time.Since(lastSent) < interval) {
+			return
 		}
+		lastPercent, lastSent = percent, time.Now()
 
-		return nil
-	})
-	if err != nil {
-		return fmt.Errorf("walk source dir: %w", err)
+		s.log.Debug("Transcoding progress", "percent", percent)
+		select {
+		case updates <- int16(percent):
+		default:
+			// Replace the report the sender has not picked up yet.
+			select {
+			case <-updates:
+			default:
+			}
+			updates <- int16(percent)
+		}
 	}
-
-	return nil
+	stop := func() {
+		close(updates)
+		<-sent
+	}
+	return report, stop
 }
 
-func (s *Service) Process(ctx context.Context) error {
-	if err := s.UpdateMetadata(ctx, UpdateMetadataRequest{Status: db.VideoStatusUPLOADED}); err != nil {
-		s.log.Error(MsgVideoMetadataUpdateFailed, "err", err.Error())
+func (s *Service) Process(ctx context.Context, job *Job) error {
+	if len(job.Packaging) == 0 {
+		job.Packaging = s.packaging
+	}
+	if err := s.CreateJob(ctx, job); err != nil {
+		s.log.Error(MsgJobUpdateFailed, "err", err.Error())
 	}
+	if !job.Republish {
+		if err := s.UpdateMetadata(ctx, job, UpdateMetadataRequest{Status: db.VideoStatusUPLOADED}); err != nil {
+			s.log.Error(MsgVideoMetadataUpdateFailed, "err", err.Error())
+		}
+	}
+	defer s.keepJobAlive(ctx, job)()
 
-	workDir := "./tmp/workspace"
-	if err := os.MkdirAll(workDir, 0o755); err != nil {
-		return fmt.Errorf("create work dir: %w", err)
+	// fail marks the job and video FAILED and returns err wrapped with stage.
+	// A rejected source also records its reason code. The updates use a
+	// detached context so they still go out after ctx was cancelled by a
+	// shutdown. In daemon mode the video then goes back to UPLOADED, ready
+	// for the redelivered message. A one-shot message is never redelivered,
+	// so the video stays PROCESSING for the API's stale job reaper to
+	// re-enqueue. A failed republish only fails the job; the video keeps
+	// its status and outputs, and the uploads of the republish are deleted
+	// unless they were already reported.
+	fail := func(stage string, err error, markVideoFailed bool) error {
+		var rejected *RejectedError
+		errors.As(err, &rejected)
+		err = fmt.Errorf("%s: %w", stage, err)
+		updateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalUpdateTimeout)
+		defer cancel()
+		switch {
+		case job.Republish:
+		case ctx.Err() != nil:
+			if s.cfg.Event == "" {
+				s.UpdateMetadata(updateCtx, job, UpdateMetadataRequest{Status: db.VideoStatusUPLOADED})
+			}
+		case rejected != nil:
+			s.UpdateMetadata(updateCtx, job, UpdateMetadataRequest{Status: db.VideoStatusFAILED, FailureReason: rejected.Reason})
+		case markVideoFailed:
+			s.UpdateMetadata(updateCtx, job, UpdateMetadataRequest{Status: db.VideoStatusFAILED})
+		}
+		if jobErr := s.UpdateJob(updateCtx, job, db.JobStatusFAILED, err); jobErr != nil {
+			s.log.Error(MsgJobUpdateFailed, "err", jobErr.Error())
+		}
+		if job.Republish && !job.OutputsReported {
+			if removeErr := s.RemoveOutputs(updateCtx, job); removeErr != nil {
+				s.log.Warn("Failed to remove outputs of failed republish", "prefix", job.OutputPrefix(), "err", removeErr)
+			}
+		}
+		return err
 	}
 
-	inputPath := filepath.Join(workDir, "input.mp4")
-	outputPath := filepath.Join(workDir, "output")
-	if err := os.MkdirAll(outputPath, 0o755); err != nil {
-		return fmt.Errorf("create output dir: %w", err)
+	if err := job.CheckContainer(); err != nil {
+		return fail("check container", err, true)
 	}
 
+	ws, err := s.NewWorkspace(job)
+	if err != nil {
+		return fail("create workspace", err, false)
+	}
 	defer func() {
-		if _, err := os.Stat(inputPath); err == nil {
-			os.Remove(inputPath)
-		}
-		if _, err := os.Stat(outputPath); err == nil {
-			os.RemoveAll(outputPath)
+		if err := ws.Remove(); err != nil {
+			s.log.Warn("Failed to remove workspace", "dir", ws.Dir, "err", err)
 		}
 	}()
 
-	if err := s.Download(ctx, inputPath); err != nil {
-		return fmt.Errorf("download video: %w", err)
+	if err := s.Download(ctx, job, ws.Input); err != nil {
+		return fail("download video", err, false)
+	}
+
+	if err := s.Analyze(ctx, job, ws.Input); err != nil {
+		return fail("analyze video", err, true)
 	}
 
-	if err := s.UpdateMetadata(ctx, UpdateMetadataRequest{Status: db.VideoStatusPROCESSING}); err != nil {
+	timeout := s.jobTimeout(job)
+	jobCtx, cancel := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("job timed out after %s", timeout))
+	defer cancel()
+
+	var noProgress int16
+	durationSec := int32(math.Round(job.Source.DurationSec()))
+	metadata := job.Source.Metadata()
+	processing := UpdateMetadataRequest{
+		Status:   db.VideoStatusPROCESSING,
+		Progress: &noProgress,
+		Metadata: &metadata,
+	}
+	if durationSec > 0 {
+		processing.DurationSec = &durationSec
+	}
+	if job.Republish {
+		processing.Status, processing.Progress = "", nil
+	}
+	if err := s.UpdateMetadata(ctx, job, processing); err != nil {
 		s.log.Error(MsgVideoMetadataUpdateFailed, "err", err.Error())
 	}
 
-	if err := s.Transcode(ctx, inputPath, outputPath); err != nil {
-		s.UpdateMetadata(ctx, UpdateMetadataRequest{Status: db.VideoStatusFAILED})
-		return fmt.Errorf("transcode video: %w", err)
+	if err := s.BuildLadder(jobCtx, job, ws.Input, ws.Samples); err != nil {
+		return fail("build ladder", err, true)
+	}
+
+	if err := s.UpdateJob(ctx, job, db.JobStatusRUNNING, nil); err != nil {
+		s.log.Error(MsgJobUpdateFailed, "err", err.Error())
 	}
 
-	if err := s.Upload(ctx, outputPath); err != nil {
-		s.UpdateMetadata(ctx, UpdateMetadataRequest{Status: db.VideoStatusFAILED})
-		return fmt.Errorf("upload files: %w", err)
+	if err := s.Transcode(jobCtx, job, ws.Input, ws.Output); err != nil {
+		return fail("transcode video", err, true)
 	}
-	if err := s.UpdateMetadata(ctx, UpdateMetadataRequest{Status: db.VideoStatusREADY}); err != nil {
+
+	if err := s.Package(jobCtx, job, ws); err != nil {
+		return fail("package outputs", err, true)
+	}
+
+	if err := s.Sprites(jobCtx, job, ws.Input, filepath.Join(ws.Output, spriteDir)); err != nil {
+		s.log.Warn("Trick-play sprite generation failed", "err", err.Error())
+	}
+
+	if err := s.Upload(jobCtx, job, ws.Output); err != nil {
+		return fail("upload files", err, true)
+	}
+
+	if err := s.Thumbnails(jobCtx, job, ws.Input, ws.Thumbnails); err != nil {
+		s.log.Warn("Thumbnail generation failed", "err", err.Error())
+	}
+
+	if err := s.ReportOutputs(ctx, job, jobOutputs(job)); err != nil {
+		return fail("report outputs", err, true)
+	}
+	job.OutputsReported = true
+	ready := UpdateMetadataRequest{Status: db.VideoStatusREADY, ThumbnailKeys: job.ThumbnailKeys}
+	if job.Republish {
+		// The video kept its status, e.g. PUBLIC, throughout.
+		ready.Status = ""
+	}
+	if job.PosterKey != "" {
+		ready.PosterKey = &job.PosterKey
+	}
+	if err := s.UpdateMetadata(ctx, job, ready); err != nil {
 		s.log.Error(MsgVideoMetadataUpdateFailed, "err", err.Error())
-		return err
+		return fail("mark video ready", err, false)
+	}
+	s.pruneOutputs(ctx, job)
+	if err := s.UpdateJob(ctx, job, db.JobStatusSUCCESS, nil); err != nil {
+		s.log.Error(MsgJobUpdateFailed, "err", err.Error())
 	}
 	return nil
 }
 
+// pruneOutputs runs PruneOutputs once the output report and ready update
+// were delivered. While either still waits in the outbox the old outputs
+// may be served, so they are left for the next job of the video to prune.
+func (s *Service) pruneOutputs(ctx context.Context, job *Job) {
+	s.outbox.mu.Lock()
+	queued, err := s.outbox.list()
+	s.outbox.mu.Unlock()
+	if err != nil || len(queued) > 0 {
+		s.log.Warn("Callbacks still queued, not pruning old outputs", "prefix", job.OutputRoot(), "queued", len(queued), "err", err)
+		return
+	}
+	if err := s.PruneOutputs(ctx, job); err != nil {
+		s.log.Warn("Failed to prune old outputs", "prefix", job.OutputRoot(), "err", err)
+	}
+}
+
+// keepJobAlive sends a heartbeat every NotifierService.HeartbeatIntervalSec
+// until the returned func is called, so no stage of the job looks stale to
+// the API's reaper.
+func (s *Service) keepJobAlive(ctx context.Context, job *Job) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	interval := time.Duration(s.cfg.NotifierService.HeartbeatIntervalSec) * time.Second
+	if interval <= 0 {
+		return cancel
+	}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Heartbeat(ctx, job); err != nil && ctx.Err() == nil {
+					s.log.Warn("Failed to send heartbeat", "err", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// jobTimeout scales the processing deadline of job with its source duration.
+func (s *Service) jobTimeout(job *Job) time.Duration {
+	cfg := s.cfg.Transcode
+	scaled := time.Duration(job.Source.DurationSec() * cfg.TimeoutFactor * float64(time.Second))
+	return max(scaled, time.Duration(cfg.MinTimeoutSec)*time.Second)
+}
+
 func (s *Service) Run(ctx context.Context) {
 	s.log.Info("Transcorder worker started processing")
-	if err := s.Process(ctx); err != nil {
+	s.SweepWorkspaces()
+	s.ReplayOutbox(ctx)
+	if s.cfg.Event == "" {
+		s.Poll(ctx)
+		return
+	}
+
+	job, err := NewJob(s.cfg.Event)
+	if err != nil {
+		s.log.Fatal("failed to unmarshell SQS_MESSAGE", "err", err)
+	}
+	if err := s.Process(ctx, job); err != nil {
 		s.log.Error("Error processing video", "error", err)
 	} else {
 		s.log.Info("Video processing completed successfully")
 	}
+	if !s.DrainOutbox(ctx) {
+		s.log.Error("Exiting with undelivered callbacks in the outbox", "dir", s.cfg.NotifierService.OutboxDir)
+	}
 }
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ownerFile is locked by the worker using a workspace for as long as the
+// job runs, so a sweep never removes the workspace of a running job, even
+// one of another worker sharing the scratch volume. It names the host and
+// PID of the owner for debugging.
+const ownerFile = ".owner"
+
+var (
+	ErrScratchFull     = errors.New("scratch space limit reached")
+	errWorkspaceLocked = errors.New("workspace is in use")
+)
+
+// Workspace is the private scratch directory of a single job attempt.
+type Workspace struct {
+	Dir        string
+	Input      string
+	Output     string
+	Thumbnails string
+	// Packaging holds intermediate files of the encryption passes.
+	Packaging string
+	// Samples holds the per-title sample encodes.
+	Samples string
+	// owner holds the lock on ownerFile until Remove.
+	owner *os.File
+}
+
+// NewWorkspace creates <scratch root>/<video ID>-<attempt> for job, or
+// <video ID>-<random> when the attempt is unknown. What an earlier run of
+// the same attempt left is removed; a workspace still locked by a running
+// job is never reused. The input keeps the source extension so ffmpeg can
+// tell the container apart.
+func (s *Service) NewWorkspace(job *Job) (*Workspace, error) {
+	_, videoID := job.UserAndVideoID()
+	suffix := strconv.Itoa(int(job.Attempt))
+	if job.Attempt == 0 {
+		suffix = uuid.NewString()[:8]
+	}
+	dir := filepath.Join(s.cfg.Workspace.Root, filepath.Base(videoID)+"-"+suffix)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	owner, err := lockWorkspace(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", dir, err)
+	}
+
+	ws := &Workspace{
+		Dir:        dir,
+		Input:      filepath.Join(dir, "input"+job.Extension()),
+		Output:     filepath.Join(dir, "output"),
+		Thumbnails: filepath.Join(dir, "thumbnails"),
+		Packaging:  filepath.Join(dir, "packaging"),
+		Samples:    filepath.Join(dir, "samples"),
+		owner:      owner,
+	}
+	if err := ws.init(); err != nil {
+		ws.Remove()
+		return nil, err
+	}
+	if err := s.checkScratchLimit(job); err != nil {
+		ws.Remove()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// init clears what an earlier run left in the workspace, records the owner
+// and creates the output directory.
+func (w *Workspace) init() error {
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == ownerFile {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(w.Dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	if w.owner != nil {
+		host, _ := os.Hostname()
+		if err := w.owner.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := w.owner.WriteAt([]byte(fmt.Sprintf("%s %d\n", host, os.Getpid())), 0); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(w.Output, 0o755)
+}
+
+// Remove deletes the workspace and everything in it, then releases it.
+func (w *Workspace) Remove() error {
+	err := os.RemoveAll(w.Dir)
+	if w.owner != nil {
+		w.owner.Close()
+	}
+	return err
+}
+
+// checkScratchLimit fails when downloading the job source would push the
+// scratch root past Workspace.MaxSizeMB.
+func (s *Service) checkScratchLimit(job *Job) error {
+	limit := s.cfg.Workspace.MaxSizeMB * mb
+	if limit <= 0 {
+		return nil
+	}
+	used, err := dirSize(s.cfg.Workspace.Root)
+	if err != nil {
+		return fmt.Errorf("measure scratch root: %w", err)
+	}
+	need := job.ObjectSize() * downloadSpaceFactor
+	if used+need > limit {
+		return fmt.Errorf("%w: %d bytes used, %d needed, limit %d", ErrScratchFull, used, need, limit)
+	}
+	return nil
+}
+
+// SweepWorkspaces removes workspaces left behind by workers that are no
+// longer running, e.g. after a crash or an OOM kill. Workspaces locked by
+// a running job, in this or another worker, are kept.
+func (s *Service) SweepWorkspaces() {
+	root := s.cfg.Workspace.Root
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		s.log.Warn("Failed to read scratch root", "root", root, "err", err)
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		owner, err := lockWorkspace(dir)
+		if err != nil {
+			if !errors.Is(err, errWorkspaceLocked) && !errors.Is(err, errors.ErrUnsupported) {
+				s.log.Warn("Failed to lock stale workspace", "dir", dir, "err", err)
+			}
+			continue
+		}
+		err = os.RemoveAll(dir)
+		owner.Close()
+		if err != nil {
+			s.log.Warn("Failed to remove stale workspace", "dir", dir, "err", err)
+			continue
+		}
+		s.log.Info("Removed stale workspace", "dir", dir)
+	}
+}
+
+// lockWorkspace locks the owner file of dir, creating it if needed. It fails
+// with errWorkspaceLocked while a running job holds the workspace; locks
+// are released by the kernel when their worker exits, however it exits.
+func lockWorkspace(dir string) (*os.File, error) {
+	owner, err := os.OpenFile(filepath.Join(dir, ownerFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(owner); err != nil {
+		owner.Close()
+		return nil, err
+	}
+	return owner, nil
+}
+
+// dirSize returns the total size of the regular files under root.
+func dirSize(root string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}